@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/logging"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// openDB connects to the configured database for an administrative
+// command. Logs go to stderr so stdout stays usable in pipes.
+func openDB(cmd *cobra.Command) (*database.DB, *config.Config, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger, _, err := logging.New(os.Stderr, cfg.Log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := database.New(cfg.Database.DSN, logger.With("component", "database"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to database: %w", err)
+	}
+	return db, cfg, nil
+}
+
+// openMigratedDB is openDB for commands that need the current schema.
+func openMigratedDB(cmd *cobra.Command) (*database.DB, *config.Config, error) {
+	db, cfg, err := openDB(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := db.CheckMigrations(cmd.Context()); err != nil {
+		_ = db.Close()
+		if errors.Is(err, database.ErrPendingMigrations) {
+			return nil, nil, fmt.Errorf("%w (run goapp migrate)", err)
+		}
+		return nil, nil, err
+	}
+	return db, cfg, nil
+}
@@ -2,38 +2,48 @@ package main
 
 import (
 	"fmt"
-	"goapp/internal/pkg/api"
 	"goapp/internal/pkg/config"
-	"goapp/internal/pkg/database"
-	"log"
 	"os"
-	"os/signal"
-	"syscall"
+
+	"github.com/spf13/cobra"
 )
 
 func main() {
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
+}
 
-	db, err := database.New(cfg.Database.DSN)
-	if err != nil {
-		log.Fatalf("Failed to create database service: %v", err)
+// newRootCmd builds the goapp command tree. Running goapp without a
+// subcommand serves HTTP, as the binary always did.
+func newRootCmd() *cobra.Command {
+	serveCmd := newServeCmd()
+
+	root := &cobra.Command{
+		Use:           "goapp",
+		Short:         "User management web application",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          serveCmd.RunE,
 	}
-	defer db.Close()
 
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
+	config.RegisterFlags(root.PersistentFlags())
 
-	myApi := api.NewApi(addr, db, cfg.Templates.Path)
-	myApi.Start()
+	root.AddCommand(
+		serveCmd,
+		newMigrateCmd(),
+		newUsersCmd(),
+		newSeedCmd(),
+		newConfigCmd(),
+		newVersionCmd(),
+	)
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-
-	sig := <-stop
-	log.Printf("received signal: %s", sig.String())
+	return root
+}
 
-	myApi.Stop()
-	log.Println("shutdown complete")
+// loadConfig loads the configuration with the global flags of cmd applied.
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	return config.Load(config.WithFlags(cmd.Flags()))
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"goapp/internal/pkg/database"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd() *cobra.Command {
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Apply pending schema migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, _, err := openDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			applied, err := db.Migrate(cmd.Context())
+			for _, m := range applied {
+				fmt.Fprintf(cmd.OutOrStdout(), "applied %04d_%s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				return err
+			}
+			if len(applied) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "schema is up to date")
+			}
+			return nil
+		},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the database schema (runs up without a subcommand)",
+		Args:  cobra.NoArgs,
+		RunE:  up.RunE,
+	}
+
+	cmd.AddCommand(up, &cobra.Command{
+		Use:   "status",
+		Short: "List migrations and whether they have been applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, _, err := openDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			pending, err := db.PendingMigrations(cmd.Context())
+			if err != nil {
+				return err
+			}
+			isPending := make(map[int]bool, len(pending))
+			for _, m := range pending {
+				isPending[m.Version] = true
+			}
+
+			all, err := database.Migrations()
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS")
+			for _, m := range all {
+				status := "applied"
+				if isPending[m.Version] {
+					status = "pending"
+				}
+				fmt.Fprintf(tw, "%04d\t%s\t%s\n", m.Version, m.Name, status)
+			}
+			return tw.Flush()
+		},
+	})
+
+	return cmd
+}
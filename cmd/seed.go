@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"goapp/internal/pkg/avatar"
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/seed"
+	"goapp/internal/pkg/storage"
+	"maps"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+func newSeedCmd() *cobra.Command {
+	var opts seed.Options
+
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Create realistic sample users for development",
+		Long: "Create realistic sample users for development. The same --seed always produces the same users;\n" +
+			"without it a random seed is used and printed so the run can be repeated.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Count < 1 {
+				return fmt.Errorf("--count must be positive")
+			}
+			if !cmd.Flags().Changed("seed") {
+				opts.Seed = rand.Uint64N(1 << 32)
+			}
+
+			db, cfg, err := openMigratedDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			res, err := seed.Run(cmd.Context(), db, opts)
+			if res.Deleted > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "deleted %d users\n", res.Deleted)
+			}
+			if len(res.Avatars) > 0 {
+				deleteAvatars(cmd, cfg, res.Avatars)
+			}
+			if res.Created > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "created %d users with seed %d\n", res.Created, opts.Seed)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().IntVarP(&opts.Count, "count", "n", 100, "number of users to create")
+	cmd.Flags().Uint64Var(&opts.Seed, "seed", 0, "random seed (default: random)")
+	cmd.Flags().IntVar(&opts.BatchSize, "batch-size", 500, "users inserted per transaction")
+	cmd.Flags().BoolVar(&opts.Wipe, "wipe", false, "delete all existing users first")
+
+	return cmd
+}
+
+// deleteAvatars removes the files of the avatars of deleted users, given
+// as versions by user id. Failures only leave unused files behind, so they
+// are reported as warnings.
+func deleteAvatars(cmd *cobra.Command, cfg *config.Config, avatars map[int64]string) {
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "warning: avatar files not deleted: %v\n", err)
+		return
+	}
+	for _, id := range slices.Sorted(maps.Keys(avatars)) {
+		if err := avatar.Delete(cmd.Context(), store, id, avatars[id]); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: avatar files of user %d not deleted: %v\n", id, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/api"
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/lifecycle"
+	"goapp/internal/pkg/logging"
+	"goapp/internal/pkg/metrics"
+	"goapp/internal/pkg/tracing"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+func newServeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Run the HTTP server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			watcher, err := config.Watch(config.WithFlags(cmd.Flags()))
+			if err != nil {
+				return err
+			}
+			defer watcher.Close()
+
+			return serve(watcher)
+		},
+	}
+}
+
+func serve(watcher *config.Watcher) error {
+	cfg := watcher.Config()
+
+	logger, logLevel, err := logging.New(os.Stderr, cfg.Log)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	logger.Info("configuration loaded", "files", watcher.Files())
+
+	shutdown := lifecycle.NewShutdown(logger.With("component", "lifecycle"))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	// Once the server runs, the shutdown hooks flush the exporter. A step
+	// failing before that has to do it here.
+	started := false
+	defer func() {
+		if started {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("failed to shut down tracing", "error", err)
+		}
+	}()
+
+	db, err := database.New(cfg.Database.DSN, logger.With("component", "database"))
+	if err != nil {
+		return fmt.Errorf("create database service: %w", err)
+	}
+
+	if cfg.Database.AutoMigrate {
+		applied, err := db.Migrate(context.Background())
+		if err != nil {
+			_ = db.Close()
+			return fmt.Errorf("apply migrations: %w", err)
+		}
+		for _, m := range applied {
+			logger.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+	}
+
+	var m *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		m = metrics.New()
+		m.RegisterDBStats(db.Conn, "mysql")
+		m.RegisterUserCount(db.CountUsers)
+		db.Metrics = m
+	}
+
+	myApi, err := api.NewApi(cfg, db, db, logger.With("component", "api"), m)
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("create api: %w", err)
+	}
+	watcher.OnError(func(err error) {
+		logger.Error("config reload failed, keeping previous config", "files", watcher.Files(), "error", err)
+	})
+	// A failed Reload leaves the watcher on the previous config, so saving
+	// the file again retries it.
+	watcher.Subscribe(func(u config.Update) error {
+		if len(u.RestartRequired) > 0 {
+			logger.Warn("config changes require a restart", "keys", u.RestartRequired)
+		}
+		if len(u.Reloaded) == 0 {
+			return nil
+		}
+
+		if err := myApi.Reload(u.New); err != nil {
+			return fmt.Errorf("apply config: %w", err)
+		}
+		// Set only once nothing can fail, so a rejected update leaves the
+		// level of the config still in effect.
+		if level, err := logging.ParseLevel(u.New.Log.Level); err == nil {
+			logLevel.Set(level)
+		}
+		logger.Info("config reloaded", "keys", u.Reloaded)
+		return nil
+	})
+
+	myApi.AddReadinessCheck("database", db.Ping)
+	myApi.AddReadinessCheck("migrations", db.CheckMigrations)
+
+	if err := myApi.Start(); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("start server: %w", err)
+	}
+	started = true
+	logger.Info("server started", "addr", cfg.Server.Address(), "tls", cfg.Server.TLS.Enabled())
+
+	// Hooks run in this order: stop taking requests first, then flush the
+	// background exporters they fed, and close the database last.
+	shutdown.Add("http", myApi.Stop)
+	shutdown.Add("config watcher", func(context.Context) error { return watcher.Close() })
+	shutdown.Add("tracing", shutdownTracing)
+	shutdown.Add("database", func(context.Context) error { return db.Close() })
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	var serveErr error
+	select {
+	case sig := <-stop:
+		logger.Info("received signal", "signal", sig.String())
+	case serveErr = <-myApi.Errors():
+		logger.Error("server stopped unexpectedly", "error", serveErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := shutdown.Run(ctx); err != nil {
+		if errors.Is(err, lifecycle.ErrTimeout) {
+			return fmt.Errorf("shutdown timed out after %s", cfg.Server.ShutdownTimeout)
+		}
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	logger.Info("shutdown complete")
+	return serveErr
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/avatar"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/storage"
+	"goapp/internal/pkg/userio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// exportPageSize is how many users export reads per query.
+const exportPageSize = 500
+
+func newUsersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "users",
+		Short: "Administer users",
+	}
+
+	cmd.AddCommand(
+		newUsersListCmd(),
+		newUsersCreateCmd(),
+		newUsersDeleteCmd(),
+		newUsersTagCmd(),
+		newUsersImportCmd(),
+		newUsersExportCmd(),
+	)
+
+	return cmd
+}
+
+// queryFlags are the filters shared by list and export.
+type queryFlags struct {
+	search, sort, createdAfter, createdBefore, bornAfter, bornBefore string
+	group                                                            int64
+	tags                                                             []string
+}
+
+func (f *queryFlags) register(fs *pflag.FlagSet) {
+	fs.StringVarP(&f.search, "search", "s", "", "only users whose name or email contains this")
+	fs.StringVar(&f.sort, "sort", "", "sort by id, name, email, age, birth_date, created_at or updated_at; prefix with - for descending order")
+	fs.StringVar(&f.createdAfter, "created-after", "", "only users created at or after this date or RFC 3339 time")
+	fs.StringVar(&f.createdBefore, "created-before", "", "only users created before this date or RFC 3339 time")
+	fs.StringVar(&f.bornAfter, "born-after", "", "only users born on or after this date")
+	fs.StringVar(&f.bornBefore, "born-before", "", "only users born before this date")
+	fs.Int64Var(&f.group, "group", 0, "only members of the group with this id")
+	fs.StringArrayVar(&f.tags, "tag", nil, "only users with this tag (repeatable; users must have every tag)")
+}
+
+func (f *queryFlags) query() (database.UserQuery, error) {
+	q := database.UserQuery{Search: f.search, Sort: f.sort, GroupID: f.group}
+	if err := q.Validate(); err != nil {
+		return q, err
+	}
+
+	var err error
+	if q.Tags, err = database.NormalizeTags(f.tags); err != nil {
+		return q, fmt.Errorf("--tag: %w", err)
+	}
+	if f.createdAfter != "" {
+		if q.CreatedAfter, err = database.ParseTime(f.createdAfter); err != nil {
+			return q, fmt.Errorf("--created-after: %w", err)
+		}
+	}
+	if f.createdBefore != "" {
+		if q.CreatedBefore, err = database.ParseTime(f.createdBefore); err != nil {
+			return q, fmt.Errorf("--created-before: %w", err)
+		}
+	}
+	if f.bornAfter != "" {
+		if q.BornAfter, err = database.ParseDate(f.bornAfter); err != nil {
+			return q, fmt.Errorf("--born-after: %w", err)
+		}
+	}
+	if f.bornBefore != "" {
+		if q.BornBefore, err = database.ParseDate(f.bornBefore); err != nil {
+			return q, fmt.Errorf("--born-before: %w", err)
+		}
+	}
+	return q, nil
+}
+
+func newUsersListCmd() *cobra.Command {
+	var limit, offset int
+	var format string
+	var filters queryFlags
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q, err := filters.query()
+			if err != nil {
+				return err
+			}
+			q.Limit, q.Offset = limit, offset
+
+			db, cfg, err := openMigratedDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			users, err := db.GetUsers(cmd.Context(), q)
+			if err != nil {
+				return err
+			}
+
+			if format == "table" {
+				return writeUsersTable(cmd.OutOrStdout(), users)
+			}
+			return userio.Write(cmd.OutOrStdout(), format, users, cfg.Users.Attributes)
+		},
+	}
+
+	cmd.Flags().IntVar(&limit, "limit", 50, "maximum number of users")
+	cmd.Flags().IntVar(&offset, "offset", 0, "number of users to skip")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table, csv or json")
+	filters.register(cmd.Flags())
+
+	return cmd
+}
+
+func writeUsersTable(w io.Writer, users []database.User) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tAGE\tCREATED\tTAGS")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\n", u.ID, u.Name, u.Email, u.Age(), u.CreatedAt.Local().Format(time.DateTime), database.FormatTags(u.Tags))
+	}
+	return tw.Flush()
+}
+
+func newUsersCreateCmd() *cobra.Command {
+	var u database.User
+	var birthDate, tags string
+	var attrs []string
+	var manager int64
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if birthDate != "" {
+				if u.BirthDate, err = database.ParseDate(birthDate); err != nil {
+					return fmt.Errorf("--birth-date: %w", err)
+				}
+			}
+			if u.Tags, err = database.ParseTags(tags); err != nil {
+				return fmt.Errorf("--tags: %w", err)
+			}
+			if manager != 0 {
+				u.ManagerID = &manager
+			}
+			values := make(map[string]any, len(attrs))
+			for _, attr := range attrs {
+				name, value, ok := strings.Cut(attr, "=")
+				if !ok {
+					return fmt.Errorf("--attr %q: expected NAME=VALUE", attr)
+				}
+				values[name] = value
+			}
+			if u.Attributes, err = cfg.Users.Attributes.Normalize(values); err != nil {
+				return err
+			}
+			if err := u.Validate(); err != nil {
+				return err
+			}
+
+			db, _, err := openMigratedDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if err := db.CreateUser(cmd.Context(), &u); err != nil {
+				if database.IsDuplicate(err) {
+					return errors.New("email already exists")
+				}
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "created user %d\n", u.ID)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&u.Name, "name", "", "name (required)")
+	cmd.Flags().StringVar(&u.Email, "email", "", "email address (required)")
+	cmd.Flags().StringVar(&birthDate, "birth-date", "", "date of birth as 2006-01-02 (required)")
+	cmd.Flags().StringVar(&u.Phone, "phone", "", "phone number")
+	cmd.Flags().StringVar(&u.Department, "department", "", "department")
+	cmd.Flags().StringVar(&u.Title, "title", "", "job title")
+	cmd.Flags().StringVar(&u.Locale, "locale", "", "preferred locale, such as en-US")
+	cmd.Flags().StringVar(&tags, "tags", "", "comma separated tags")
+	cmd.Flags().Int64Var(&manager, "manager", 0, "id of the user's manager")
+	cmd.Flags().StringArrayVar(&attrs, "attr", nil, "custom attribute as NAME=VALUE (repeatable)")
+
+	return cmd
+}
+
+func newUsersTagCmd() *cobra.Command {
+	var add, remove []string
+
+	cmd := &cobra.Command{
+		Use:   "tag ID...",
+		Short: "Add tags to or remove tags from users",
+		Long:  "Add the --add tags to each of the users and remove the --remove tags. Nothing changes if one of the users doesn't exist.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(add) == 0 && len(remove) == 0 {
+				return errors.New("nothing to do: pass --add or --remove")
+			}
+			ids := make([]int64, 0, len(args))
+			for _, arg := range args {
+				id, err := strconv.ParseInt(arg, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid id %q", arg)
+				}
+				ids = append(ids, id)
+			}
+
+			db, _, err := openMigratedDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if err := db.TagUsers(cmd.Context(), ids, add, remove); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "updated tags of %d user(s)\n", len(ids))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&add, "add", nil, "tags to add (comma separated or repeated)")
+	cmd.Flags().StringSliceVar(&remove, "remove", nil, "tags to remove (comma separated or repeated)")
+
+	return cmd
+}
+
+func newUsersDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete ID...",
+		Short: "Delete users by ID",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids := make([]int64, 0, len(args))
+			for _, arg := range args {
+				id, err := strconv.ParseInt(arg, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid id %q", arg)
+				}
+				ids = append(ids, id)
+			}
+
+			db, cfg, err := openMigratedDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			// The store is only opened for users that have an avatar.
+			var store storage.Store
+			var errs []error
+			for _, id := range ids {
+				var version string
+				if u, err := db.GetUserByID(cmd.Context(), id); err == nil {
+					version = u.Avatar
+				}
+				if err := db.DeleteUser(cmd.Context(), id); err != nil {
+					errs = append(errs, fmt.Errorf("user %d: %w", id, err))
+					continue
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "deleted user %d\n", id)
+
+				if version == "" {
+					continue
+				}
+				if store == nil {
+					if store, err = storage.New(cfg.Storage); err != nil {
+						return errors.Join(append(errs, err)...)
+					}
+				}
+				if err := avatar.Delete(cmd.Context(), store, id, version); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "warning: avatar files of user %d not deleted: %v\n", id, err)
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
+
+func newUsersImportCmd() *cobra.Command {
+	var format string
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Create users from a CSV or JSON file (- reads stdin)",
+		Long: "Create users from a CSV file with name, email and birth_date columns, or a JSON array as written by export.\n" +
+			"Files with an age column instead of birth_date, as exported by older versions, get approximate birth dates.\n" +
+			"CSV files may also have phone, department, title, locale and tags columns, and attr.NAME columns for custom attributes.\n" +
+			"The whole file is validated first and imported in one transaction, so either every user is created or none.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			in := cmd.InOrStdin()
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+
+				if format == "" {
+					format = userio.FormatFromPath(args[0])
+				}
+			}
+			if format == "" {
+				format = userio.FormatCSV
+			}
+
+			users, err := userio.Read(in, format, cfg.Users.Attributes)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", args[0], err)
+			}
+			if dryRun {
+				fmt.Fprintf(cmd.OutOrStdout(), "%d users are valid\n", len(users))
+				return nil
+			}
+
+			db, _, err := openMigratedDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if err := db.CreateUsers(cmd.Context(), users); err != nil {
+				if database.IsDuplicate(err) {
+					return fmt.Errorf("nothing imported: %w", err)
+				}
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "imported %d users\n", len(users))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "input format: csv or json (default: from the file extension)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only validate the file")
+
+	return cmd
+}
+
+func newUsersExportCmd() *cobra.Command {
+	var format, output string
+	var filters queryFlags
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write all users, or those matching the filters, as CSV or JSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = userio.FormatFromPath(output)
+			}
+			q, err := filters.query()
+			if err != nil {
+				return err
+			}
+			q.Limit = exportPageSize
+
+			db, cfg, err := openMigratedDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			users := make([]database.User, 0)
+			for q.Offset = 0; ; q.Offset += exportPageSize {
+				page, err := db.GetUsers(cmd.Context(), q)
+				if err != nil {
+					return err
+				}
+				users = append(users, page...)
+				if len(page) < exportPageSize {
+					break
+				}
+			}
+
+			if output == "" {
+				return userio.Write(cmd.OutOrStdout(), format, users, cfg.Users.Attributes)
+			}
+
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			if err := userio.Write(f, format, users, cfg.Users.Attributes); err != nil {
+				_ = f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "exported %d users to %s\n", len(users), output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "", "output format: csv or json (default: from --output, else csv)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write to this file instead of stdout")
+	filters.register(cmd.Flags())
+
+	return cmd
+}
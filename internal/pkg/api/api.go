@@ -2,69 +2,388 @@ package api
 
 import (
 	"context"
-	"html/template"
-	"log"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/attributes"
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/metrics"
+	"goapp/internal/pkg/storage"
+	"log/slog"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type Api struct {
-	address   string
-	router    *mux.Router
-	server    *http.Server
-	db        UserRepository
-	templates *template.Template
+	address  string
+	router   *mux.Router
+	server   *http.Server
+	redirect *http.Server
+	certs    *certReloader
+	db       UserRepository
+	groups   GroupRepository
+	store    storage.Store
+	httpCfg  config.HTTPConfig
+	logger   *slog.Logger
+	metrics  *metrics.Metrics
+	cfg      *config.Config
+	// sessionKey signs cookies such as flash messages.
+	sessionKey []byte
+
+	readiness     []readinessCheck
+	healthTimeout time.Duration
+	shuttingDown  atomic.Bool
+	drain         time.Duration
+	errs          chan error
+
+	// Settings below can change at runtime through Reload.
+	mu           sync.RWMutex
+	templates    Renderer
+	templatesCfg config.TemplatesConfig
+	static       http.Handler
+	limits       config.LimitsConfig
+	features     map[string]bool
+	maxBodyBytes int64
+	attributes   attributes.Defs
+	avatar       config.AvatarConfig
 }
 
-func NewApi(hostPort string, db UserRepository, templatesPath string) *Api {
+func NewApi(cfg *config.Config, db UserRepository, groups GroupRepository, logger *slog.Logger, m *metrics.Metrics) (*Api, error) {
 	r := mux.NewRouter()
-	r.Use(loggingMiddleware)
-	tpl := template.Must(template.ParseGlob(templatesPath))
+	tpl, err := newRenderer(cfg.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionKey := []byte(cfg.Session.Secret)
+	if len(sessionKey) == 0 {
+		// Cookies signed with a random key don't survive a restart and
+		// aren't accepted by other instances, which is fine for flashes.
+		sessionKey = make([]byte, 32)
+		if _, err := rand.Read(sessionKey); err != nil {
+			return nil, err
+		}
+		logger.Warn("session.secret is not set, using a random key")
+	}
 
 	api := &Api{
-		address:   hostPort,
+		address:   cfg.Server.Address(),
 		router:    r,
 		db:        db,
+		groups:    groups,
+		store:     store,
 		templates: tpl,
+		httpCfg:   cfg.HTTP,
+		logger:    logger,
+		metrics:   m,
+		cfg:       cfg,
+
+		sessionKey: sessionKey,
+
+		healthTimeout: cfg.Health.Timeout,
+		drain:         cfg.Server.ShutdownDrain,
+		errs:          make(chan error, 2),
+
+		templatesCfg: cfg.Templates,
+		static:       newStaticHandler(cfg.Templates),
+		limits:       cfg.Limits,
+		features:     cfg.Features,
+		maxBodyBytes: cfg.HTTP.MaxBodyBytes,
+		attributes:   cfg.Users.Attributes,
+		avatar:       cfg.Users.Avatar,
 	}
 
+	api.registerMiddleware(cfg.HTTP.Headers)
 	api.registerHandlers()
 
 	api.server = &http.Server{
-		Addr:    api.address,
-		Handler: api.router,
+		Addr:              api.address,
+		Handler:           api.router,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+		MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	if cfg.Server.TLS.Enabled() {
+		if err := api.setupTLS(cfg.Server); err != nil {
+			return nil, err
+		}
+	}
+
+	return api, nil
+
+}
+
+// registerMiddleware wraps every response in the middleware chain. mux
+// only runs Use middleware for matched routes, so the not found and method
+// not allowed handlers are wrapped as well.
+func (api *Api) registerMiddleware(headers config.HeadersConfig) {
+	chain := []mux.MiddlewareFunc{
+		requestIDMiddleware,
+		tracingMiddleware,
+		api.loggingMiddleware,
+		api.metricsMiddleware,
+		securityHeadersMiddleware(headers),
+		localeMiddleware,
+		maxBodyMiddleware(api.bodyLimit),
+	}
+	wrap := func(h http.Handler) http.Handler {
+		for i := len(chain) - 1; i >= 0; i-- {
+			h = chain[i](h)
+		}
+		return h
+	}
+
+	api.router.Use(chain...)
+	api.router.NotFoundHandler = wrap(http.NotFoundHandler())
+	api.router.MethodNotAllowedHandler = wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+}
+
+// Reload applies the reloadable settings of cfg to the running server.
+// Templates are re-parsed only when their settings changed; if parsing
+// fails nothing is applied.
+func (api *Api) Reload(cfg *config.Config) error {
+	api.mu.RLock()
+	tpl, static, current := api.templates, api.static, api.templatesCfg
+	api.mu.RUnlock()
+
+	if cfg.Templates != current {
+		parsed, err := newRenderer(cfg.Templates)
+		if err != nil {
+			return err
+		}
+		tpl, static = parsed, newStaticHandler(cfg.Templates)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.templates = tpl
+	api.templatesCfg = cfg.Templates
+	api.static = static
+	api.limits = cfg.Limits
+	api.features = cfg.Features
+	api.maxBodyBytes = cfg.HTTP.MaxBodyBytes
+	api.attributes = cfg.Users.Attributes
+	api.avatar = cfg.Users.Avatar
+
+	return nil
+}
+
+func (api *Api) tpl() Renderer {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.templates
+}
+
+func (api *Api) serveStatic(w http.ResponseWriter, r *http.Request) {
+	api.mu.RLock()
+	static := api.static
+	api.mu.RUnlock()
+	static.ServeHTTP(w, r)
+}
+
+func (api *Api) maxBody() int64 {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.maxBodyBytes
+}
+
+// pageLimits returns the default and maximum page size for listings.
+func (api *Api) pageLimits() (int, int) {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+
+	def, max := api.limits.DefaultPageSize, api.limits.MaxPageSize
+	if max <= 0 {
+		max = 100
+	}
+	if def <= 0 || def > max {
+		def = min(10, max)
+	}
+	return def, max
+}
+
+// attributeDefs returns the custom user attributes from the config.
+func (api *Api) attributeDefs() attributes.Defs {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.attributes
+}
+
+// featureEnabled reports whether the named switch under features is on.
+func (api *Api) featureEnabled(name string) bool {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.features[name]
+}
+
+func (api *Api) setupTLS(cfg config.ServerConfig) error {
+	certs, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, api.logger)
+	if err != nil {
+		return err
+	}
+
+	tlsCfg, err := buildTLSConfig(cfg.TLS, certs)
+	if err != nil {
+		return err
+	}
+
+	if err := certs.watch(); err != nil {
+		return err
 	}
 
-	return api
+	api.certs = certs
+	api.server.TLSConfig = tlsCfg
+
+	if cfg.TLS.RedirectPort != 0 {
+		api.redirect = &http.Server{
+			Addr:              fmt.Sprintf("%s:%d", cfg.Host, cfg.TLS.RedirectPort),
+			Handler:           redirectHandler(cfg.Port),
+			ReadHeaderTimeout: api.httpCfg.ReadHeaderTimeout,
+			IdleTimeout:       api.httpCfg.IdleTimeout,
+			ErrorLog:          api.server.ErrorLog,
+		}
+	}
 
+	return nil
 }
 
 func (api *Api) registerHandlers() {
-	api.router.HandleFunc("/users", api.GetUsers).Methods(http.MethodGet)
-	api.router.HandleFunc("/users/{id}", api.GetUser).Methods(http.MethodGet)
-	api.router.HandleFunc("/users", api.CreateUser).Methods(http.MethodPost)
-	api.router.HandleFunc("/users/{id}", api.EditUser).Methods(http.MethodPost)
-	api.router.HandleFunc("/users/{id}/delete", api.DeleteUser).Methods(http.MethodPost)
-	api.router.HandleFunc("/health", api.Health).Methods(http.MethodGet)
+	api.router.HandleFunc("/users", api.GetUsers).Methods(http.MethodGet).Name("users.list")
+	api.router.HandleFunc("/users/{id}", api.GetUser).Methods(http.MethodGet).Name("users.get")
+	api.router.HandleFunc("/users/{id}/row", api.UserRow).Methods(http.MethodGet).Name("users.row")
+	api.router.HandleFunc("/users", api.CreateUser).Methods(http.MethodPost).Name("users.create")
+	api.router.HandleFunc("/users/tags", api.TagUsers).Methods(http.MethodPost).Name("users.tag")
+	api.router.HandleFunc("/users/{id}/edit", api.EditUserForm).Methods(http.MethodGet).Name("users.edit_form")
+	api.router.HandleFunc("/users/{id}/edit", api.EditUser).Methods(http.MethodPost).Name("users.edit")
+	api.router.HandleFunc("/users/{id}/delete", api.ConfirmDeleteUser).Methods(http.MethodGet).Name("users.delete_confirm")
+	api.router.HandleFunc("/users/{id}/delete", api.DeleteUser).Methods(http.MethodPost).Name("users.delete")
+	api.router.HandleFunc("/users/{id}/avatar", api.GetAvatar).Methods(http.MethodGet).Name("users.avatar")
+	api.router.HandleFunc("/users/{id}/avatar/thumb", api.GetAvatarThumb).Methods(http.MethodGet).Name("users.avatar.thumb")
+	api.router.HandleFunc("/users/{id}/avatar", api.UploadAvatar).Methods(http.MethodPost).Name("users.avatar.upload")
+	api.router.HandleFunc("/users/{id}/avatar/delete", api.DeleteAvatar).Methods(http.MethodPost).Name("users.avatar.delete")
+	api.router.HandleFunc("/org", api.GetOrg).Methods(http.MethodGet).Name("org")
+	api.router.HandleFunc("/groups", api.GetGroups).Methods(http.MethodGet).Name("groups.list")
+	api.router.HandleFunc("/groups", api.CreateGroup).Methods(http.MethodPost).Name("groups.create")
+	api.router.HandleFunc("/groups/{id}", api.GetGroup).Methods(http.MethodGet).Name("groups.get")
+	api.router.HandleFunc("/groups/{id}/edit", api.EditGroupForm).Methods(http.MethodGet).Name("groups.edit_form")
+	api.router.HandleFunc("/groups/{id}/edit", api.EditGroup).Methods(http.MethodPost).Name("groups.edit")
+	api.router.HandleFunc("/groups/{id}/delete", api.ConfirmDeleteGroup).Methods(http.MethodGet).Name("groups.delete_confirm")
+	api.router.HandleFunc("/groups/{id}/delete", api.DeleteGroup).Methods(http.MethodPost).Name("groups.delete")
+	api.router.HandleFunc("/groups/{id}/members", api.SetGroupMember).Methods(http.MethodPost).Name("groups.members.set")
+	api.router.HandleFunc("/groups/{id}/members/{user}/delete", api.RemoveGroupMember).Methods(http.MethodPost).Name("groups.members.remove")
+	api.router.HandleFunc("/api/users", api.ListUsersJSON).Methods(http.MethodGet).Name("api.users.list")
+	api.router.HandleFunc("/api/users/{id}", api.GetUserJSON).Methods(http.MethodGet).Name("api.users.get")
+	api.router.HandleFunc("/api/users/{id}/reports", api.ListReportsJSON).Methods(http.MethodGet).Name("api.users.reports")
+	api.router.HandleFunc("/api/users/{id}/managers", api.ListManagersJSON).Methods(http.MethodGet).Name("api.users.managers")
+	api.router.HandleFunc("/api/tags", api.ListTagsJSON).Methods(http.MethodGet).Name("api.tags.list")
+	api.router.HandleFunc("/api/groups", api.ListGroupsJSON).Methods(http.MethodGet).Name("api.groups.list")
+	api.router.HandleFunc("/api/groups/{id}", api.GetGroupJSON).Methods(http.MethodGet).Name("api.groups.get")
+	api.router.PathPrefix("/static/").HandlerFunc(api.serveStatic).Methods(http.MethodGet, http.MethodHead).Name("static")
+	api.router.HandleFunc("/health", api.Health).Methods(http.MethodGet).Name("health")
+	api.router.HandleFunc("/livez", api.Livez).Methods(http.MethodGet).Name("livez")
+	api.router.HandleFunc("/readyz", api.Readyz).Methods(http.MethodGet).Name("readyz")
+
+	if api.metrics != nil && api.cfg.Metrics.Enabled {
+		api.router.Handle(api.cfg.Metrics.Path, api.metrics.Handler()).Methods(http.MethodGet).Name("metrics")
+	}
 }
 
-func (api *Api) Start() {
+// Start binds the listeners before returning, so errors such as a port
+// already in use are reported to the caller. Errors that stop a server
+// later are delivered on Errors.
+func (api *Api) Start() error {
+	ln, err := net.Listen("tcp", api.server.Addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", api.server.Addr, err)
+	}
+
+	var redirectLn net.Listener
+	if api.redirect != nil {
+		redirectLn, err = net.Listen("tcp", api.redirect.Addr)
+		if err != nil {
+			_ = ln.Close()
+			return fmt.Errorf("listen on %s: %w", api.redirect.Addr, err)
+		}
+	}
+
 	go func() {
-		if err := api.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("server error: %v", err)
+		var err error
+		if api.server.TLSConfig != nil {
+			// Certificates come from TLSConfig.GetCertificate, and ServeTLS
+			// enables HTTP/2 on its own.
+			err = api.server.ServeTLS(ln, "", "")
+		} else {
+			err = api.server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			api.errs <- fmt.Errorf("server: %w", err)
 		}
 	}()
+
+	if redirectLn != nil {
+		go func() {
+			if err := api.redirect.Serve(redirectLn); err != nil && err != http.ErrServerClosed {
+				api.errs <- fmt.Errorf("redirect server: %w", err)
+			}
+		}()
+	}
+
+	return nil
 }
 
-func (api *Api) Stop() {
+func (api *Api) Errors() <-chan error {
+	return api.errs
+}
+
+// Stop fails readiness, keeps serving for the drain period so load
+// balancers stop routing new traffic here, then shuts the servers down
+// within whatever remains of ctx.
+func (api *Api) Stop(ctx context.Context) error {
 	if api.server == nil {
-		return
+		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	api.shuttingDown.Store(true)
+
+	if api.drain > 0 {
+		api.logger.Info("draining before shutdown", "drain", api.drain)
+		select {
+		case <-time.After(api.drain):
+		case <-ctx.Done():
+		}
+	}
+
+	var errs []error
+	if api.redirect != nil {
+		if err := api.redirect.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := api.server.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if api.certs != nil {
+		_ = api.certs.Close()
+	}
 
-	_ = api.server.Shutdown(ctx)
+	return errors.Join(errs...)
 }
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/database"
+)
+
+func TestStart_ReturnsListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	api := newTestAPI(&fakeUserRepo{})
+	api.server = &http.Server{Addr: ln.Addr().String(), Handler: api.router}
+
+	if err := api.Start(); err == nil {
+		t.Fatalf("expected error for port in use, got nil")
+	}
+}
+
+func TestStop_DrainsWithReadinessFailing(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.server = &http.Server{Addr: "127.0.0.1:0", Handler: api.router}
+	api.drain = 50 * time.Millisecond
+
+	if err := api.Start(); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- api.Stop(context.Background()) }()
+
+	time.Sleep(10 * time.Millisecond)
+	if !api.shuttingDown.Load() {
+		t.Fatalf("expected readiness to fail during drain")
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Stop did not return")
+	}
+}
+
+func TestReload_AppliesLimits(t *testing.T) {
+	var gotLimit int
+	api := newTestAPI(&fakeUserRepo{
+		getUsersFn: func(ctx context.Context, q database.UserQuery) ([]database.User, error) {
+			gotLimit = q.Limit
+			return nil, nil
+		},
+	})
+
+	cfg := &config.Config{
+		Limits: config.LimitsConfig{DefaultPageSize: 5, MaxPageSize: 20},
+		HTTP:   config.HTTPConfig{MaxBodyBytes: 512},
+	}
+	if err := api.Reload(cfg); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	api.GetUsers(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users?limit=50", nil))
+	if gotLimit != 20 {
+		t.Fatalf("expected limit capped at 20, got %d", gotLimit)
+	}
+
+	api.GetUsers(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+	if gotLimit != 5 {
+		t.Fatalf("expected default limit 5, got %d", gotLimit)
+	}
+
+	if api.maxBody() != 512 {
+		t.Fatalf("expected max body 512, got %d", api.maxBody())
+	}
+}
+
+func TestReload_AppliesFeatures(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.templates = template.Must(template.New("users.html").Parse("LIVE={{.LiveSearch}}"))
+
+	for _, live := range []bool{true, false} {
+		cfg := &config.Config{Features: map[string]bool{"live_search": live}}
+		if err := api.Reload(cfg); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		api.GetUsers(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+		if want := fmt.Sprintf("LIVE=%v", live); w.Body.String() != want {
+			t.Fatalf("expected %q, got %q", want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/avatar"
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/i18n"
+	"goapp/internal/pkg/storage"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// multipartOverhead is allowed on top of the avatar size for the
+// multipart boundaries and headers of an upload.
+const multipartOverhead = 64 << 10
+
+// avatarConfig returns the avatar limits and sizes from the config.
+func (api *Api) avatarConfig() config.AvatarConfig {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.avatar
+}
+
+// bodyLimit is the request body limit of r: http.max_body_bytes, except
+// for avatar uploads, which are limited by users.avatar.max_bytes.
+func (api *Api) bodyLimit(r *http.Request) int64 {
+	if cur := mux.CurrentRoute(r); cur != nil && cur.GetName() == "users.avatar.upload" {
+		return api.avatarConfig().MaxBytes + multipartOverhead
+	}
+	return api.maxBody()
+}
+
+// formatSize formats a byte count for messages, such as "5.0 MB".
+func formatSize(n int64) string {
+	if n < 1<<20 {
+		return strconv.FormatInt(n>>10, 10) + " KB"
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// UploadAvatar replaces a user's avatar with the uploaded picture, cropped
+// to a square and scaled down, and returns to the edit page.
+func (api *Api) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		http.Error(w, msg, status)
+		return
+	}
+
+	back := fmt.Sprintf("/users/%d/edit", user.ID)
+	fail := func(key string, args ...any) {
+		api.setFlash(w, r, Flash{Kind: "error", Message: l.T(key, args...)})
+		http.Redirect(w, r, back, http.StatusSeeOther)
+	}
+
+	cfg := api.avatarConfig()
+	// Parts larger than this go to temporary files, removed below.
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			fail("error.avatar_too_large", formatSize(cfg.MaxBytes))
+			return
+		}
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	// The file name and content type the browser sent are ignored;
+	// avatar.Process looks at the content.
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		fail("error.avatar_missing")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, cfg.MaxBytes+1))
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to read avatar upload", "id", user.ID, "error", err)
+		fail("error.save_avatar")
+		return
+	}
+	if int64(len(data)) > cfg.MaxBytes {
+		fail("error.avatar_too_large", formatSize(cfg.MaxBytes))
+		return
+	}
+
+	images, err := avatar.Process(data, avatar.Options{MaxPixels: cfg.MaxPixels, Size: cfg.Size, ThumbSize: cfg.ThumbSize})
+	if err != nil {
+		switch {
+		case errors.Is(err, avatar.ErrUnsupportedType):
+			fail("error.avatar_type")
+		case errors.Is(err, avatar.ErrTooManyPixels):
+			fail("error.avatar_dimensions")
+		case errors.Is(err, avatar.ErrInvalidImage):
+			fail("error.avatar_invalid")
+		default:
+			api.logger.ErrorContext(r.Context(), "failed to process avatar", "id", user.ID, "error", err)
+			fail("error.save_avatar")
+		}
+		return
+	}
+
+	version, err := avatar.Save(r.Context(), api.store, user.ID, images)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to store avatar", "id", user.ID, "error", err)
+		fail("error.save_avatar")
+		return
+	}
+	old, err := api.db.SetUserAvatar(r.Context(), user.ID, version)
+	if err != nil {
+		// The files are only in use if they were already the avatar.
+		if version != user.Avatar {
+			api.deleteAvatar(r, user.ID, version)
+		}
+		if errors.Is(err, database.ErrUserNotFound) {
+			http.Error(w, l.T("error.user_not_found"), http.StatusNotFound)
+			return
+		}
+		api.logger.ErrorContext(r.Context(), "failed to save avatar", "id", user.ID, "error", err)
+		fail("error.save_avatar")
+		return
+	}
+	if old != "" && old != version {
+		api.deleteAvatar(r, user.ID, old)
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.avatar_updated", user.Name)})
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// DeleteAvatar removes a user's avatar and returns to the edit page.
+func (api *Api) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		http.Error(w, msg, status)
+		return
+	}
+
+	old, err := api.db.SetUserAvatar(r.Context(), user.ID, "")
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			http.Error(w, l.T("error.user_not_found"), http.StatusNotFound)
+			return
+		}
+		api.logger.ErrorContext(r.Context(), "failed to remove avatar", "id", user.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if old != "" {
+		api.deleteAvatar(r, user.ID, old)
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.avatar_removed", user.Name)})
+	http.Redirect(w, r, fmt.Sprintf("/users/%d/edit", user.ID), http.StatusSeeOther)
+}
+
+// deleteAvatar removes the files of an avatar that is no longer used.
+// Failing leaves unused files behind, which is only logged.
+func (api *Api) deleteAvatar(r *http.Request, userID int64, version string) {
+	if err := avatar.Delete(r.Context(), api.store, userID, version); err != nil {
+		api.logger.WarnContext(r.Context(), "failed to delete avatar files", "id", userID, "version", version, "error", err)
+	}
+}
+
+// GetAvatar serves a user's avatar.
+func (api *Api) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	api.serveAvatar(w, r, false)
+}
+
+// GetAvatarThumb serves the thumbnail of a user's avatar.
+func (api *Api) GetAvatarThumb(w http.ResponseWriter, r *http.Request) {
+	api.serveAvatar(w, r, true)
+}
+
+func (api *Api) serveAvatar(w http.ResponseWriter, r *http.Request, thumb bool) {
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		http.Error(w, msg, status)
+		return
+	}
+	if user.Avatar == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Pages link to the avatar with ?v= set to its version. Such a URL
+	// always serves the same image, so browsers can keep it; any other
+	// is revalidated against the ETag.
+	etag := `"` + user.Avatar + `"`
+	w.Header().Set("ETag", etag)
+	if r.URL.Query().Get("v") == user.Avatar {
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	obj, err := api.store.Get(r.Context(), avatar.Key(user.ID, user.Avatar, thumb))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			api.logger.WarnContext(r.Context(), "avatar file is missing", "id", user.ID, "version", user.Avatar)
+			http.NotFound(w, r)
+			return
+		}
+		api.logger.ErrorContext(r.Context(), "failed to read avatar", "id", user.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer obj.Close()
+
+	w.Header().Set("Content-Type", avatar.ContentType)
+	if obj.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	}
+	if _, err := io.Copy(w, obj); err != nil {
+		api.logger.WarnContext(r.Context(), "failed to send avatar", "id", user.ID, "error", err)
+	}
+}
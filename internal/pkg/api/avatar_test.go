@@ -0,0 +1,235 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goapp/internal/pkg/avatar"
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/storage"
+
+	"github.com/gorilla/mux"
+)
+
+var testAvatarConfig = config.AvatarConfig{MaxBytes: 64 << 10, MaxPixels: 1_000_000, Size: 32, ThumbSize: 8}
+
+func newAvatarTestAPI(t *testing.T, repo *fakeUserRepo) (*Api, storage.Store) {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	api := newTestAPI(repo)
+	api.store = store
+	api.avatar = testAvatarConfig
+	return api, store
+}
+
+// avatarUpload builds a multipart request posting data as the avatar of
+// the user with id 7.
+func avatarUpload(t *testing.T, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("avatar", "me.png")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/7/avatar", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return mux.SetURLVars(req, map[string]string{"id": "7"})
+}
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func flashMessage(t *testing.T, api *Api, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	f := api.popFlash(httptest.NewRecorder(), req)
+	if f == nil {
+		t.Fatalf("expected a flash message")
+	}
+	return f.Message
+}
+
+func TestUploadAvatar(t *testing.T) {
+	var saved string
+	api, store := newAvatarTestAPI(t, &fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir", Avatar: "old"}, nil
+		},
+		setAvatarFn: func(ctx context.Context, id int64, version string) (string, error) {
+			saved = version
+			return "old", nil
+		},
+	})
+	for _, thumb := range []bool{false, true} {
+		if err := store.Put(context.Background(), avatar.Key(7, "old", thumb), strings.NewReader("x"), 1, avatar.ContentType); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	api.UploadAvatar(w, avatarUpload(t, pngBytes(t, 60, 40)))
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/users/7/edit" {
+		t.Fatalf("expected redirect to the edit page, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if msg := flashMessage(t, api, w); msg != "Avatar of Mahir updated" {
+		t.Fatalf("unexpected flash %q", msg)
+	}
+	for _, thumb := range []bool{false, true} {
+		obj, err := store.Get(context.Background(), avatar.Key(7, saved, thumb))
+		if err != nil {
+			t.Fatalf("expected the new avatar to be stored, got %v", err)
+		}
+		obj.Close()
+		if _, err := store.Get(context.Background(), avatar.Key(7, "old", thumb)); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected the old avatar to be deleted, got %v", err)
+		}
+	}
+}
+
+func TestUploadAvatar_Rejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "the picture must be a JPEG, PNG or GIF image"},
+		{"too large", append(pngBytes(t, 10, 10), make([]byte, 64<<10)...), "the picture is larger than 64 KB"},
+		{"too many pixels", pngBytes(t, 2000, 1000), "the picture has too many pixels"},
+		{"corrupt", pngBytes(t, 10, 10)[:40], "the picture can't be read"},
+	}
+	for _, tt := range tests {
+		api, _ := newAvatarTestAPI(t, &fakeUserRepo{
+			getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+				return &database.User{ID: id, Name: "Mahir"}, nil
+			},
+			setAvatarFn: func(ctx context.Context, id int64, version string) (string, error) {
+				t.Fatalf("%s: expected no avatar to be saved", tt.name)
+				return "", nil
+			},
+		})
+
+		w := httptest.NewRecorder()
+		api.UploadAvatar(w, avatarUpload(t, tt.data))
+
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("%s: expected 303, got %d", tt.name, w.Code)
+		}
+		if msg := flashMessage(t, api, w); msg != tt.want {
+			t.Fatalf("%s: expected %q, got %q", tt.name, tt.want, msg)
+		}
+	}
+}
+
+func TestBodyLimit_AvatarUpload(t *testing.T) {
+	api, _ := newAvatarTestAPI(t, &fakeUserRepo{})
+	api.maxBodyBytes = 1024
+	api.registerHandlers()
+
+	var got int64
+	api.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = api.bodyLimit(r)
+		})
+	})
+	for path, want := range map[string]int64{"/users/7/avatar": 64<<10 + multipartOverhead, "/users": 1024} {
+		api.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+		if got != want {
+			t.Fatalf("%s: expected limit %d, got %d", path, want, got)
+		}
+	}
+}
+
+func TestDeleteAvatar(t *testing.T) {
+	saved := "unset"
+	api, store := newAvatarTestAPI(t, &fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir", Avatar: "old"}, nil
+		},
+		setAvatarFn: func(ctx context.Context, id int64, version string) (string, error) {
+			saved = version
+			return "old", nil
+		},
+	})
+	if err := store.Put(context.Background(), avatar.Key(7, "old", false), strings.NewReader("x"), 1, avatar.ContentType); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/users/7/avatar/delete", nil), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.DeleteAvatar(w, req)
+
+	if w.Code != http.StatusSeeOther || saved != "" {
+		t.Fatalf("expected the avatar to be cleared, got %d %q", w.Code, saved)
+	}
+	if _, err := store.Get(context.Background(), avatar.Key(7, "old", false)); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the file to be deleted, got %v", err)
+	}
+}
+
+func TestGetAvatar(t *testing.T) {
+	api, store := newAvatarTestAPI(t, &fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			if id == 8 {
+				return &database.User{ID: id}, nil
+			}
+			return &database.User{ID: id, Avatar: "v1"}, nil
+		},
+	})
+	if err := store.Put(context.Background(), avatar.Key(7, "v1", true), strings.NewReader("thumb"), 5, avatar.ContentType); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	get := func(id, query string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users/"+id+"/avatar/thumb"+query, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		api.GetAvatarThumb(w, mux.SetURLVars(req, map[string]string{"id": id}))
+		return w
+	}
+
+	w := get("7", "?v=v1", nil)
+	if body, _ := io.ReadAll(w.Body); w.Code != http.StatusOK || string(body) != "thumb" || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("unexpected response %d %q %q", w.Code, body, w.Header().Get("Content-Type"))
+	}
+	if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Fatalf("expected a versioned URL to be cached, got %q", cc)
+	}
+
+	if w := get("7", "", nil); w.Header().Get("Cache-Control") != "no-cache" || w.Header().Get("ETag") != `"v1"` {
+		t.Fatalf("expected an unversioned URL to be revalidated, got %q %q", w.Header().Get("Cache-Control"), w.Header().Get("ETag"))
+	}
+	if w := get("7", "", http.Header{"If-None-Match": {`"v1"`}}); w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+	if w := get("8", "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without an avatar, got %d", w.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const flashCookie = "goapp_flash"
+
+// Flash is a one-time message shown on the page a redirect leads to.
+type Flash struct {
+	// Kind is success or error and selects the styling.
+	Kind    string `json:"k"`
+	Message string `json:"m"`
+}
+
+// setFlash stores f in a signed cookie for the next request.
+func (api *Api) setFlash(w http.ResponseWriter, r *http.Request, f Flash) {
+	payload, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Value:    value + "." + api.sign(value),
+		Path:     "/",
+		MaxAge:   int((5 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// popFlash returns the pending flash, if any, and clears it. Cookies with
+// a bad signature are ignored.
+func (api *Api) popFlash(w http.ResponseWriter, r *http.Request) *Flash {
+	c, err := r.Cookie(flashCookie)
+	if err != nil {
+		return nil
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	value, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(api.sign(value))) {
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	f := &Flash{}
+	if err := json.Unmarshal(payload, f); err != nil {
+		return nil
+	}
+	return f
+}
+
+func (api *Api) sign(value string) string {
+	mac := hmac.New(sha256.New, api.sessionKey)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
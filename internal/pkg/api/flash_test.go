@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"goapp/internal/pkg/database"
+
+	"github.com/gorilla/mux"
+)
+
+func TestFlash_ShownOnceAfterRedirect(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.sessionKey = []byte("0123456789abcdef0123456789abcdef")
+	api.templates = template.Must(template.New("root").Parse(
+		`{{define "users.html"}}FLASH={{with .Flash}}{{.Kind}}:{{.Message}}{{end}}{{end}}`,
+	))
+
+	form := url.Values{}
+	form.Set("name", "Mahir")
+	form.Set("email", "mahir@test.com")
+	form.Set("birth_date", "2000-01-02")
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	api.CreateUser(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != flashCookie {
+		t.Fatalf("expected flash cookie, got %v", cookies)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	api.GetUsers(w, req)
+
+	if got := w.Body.String(); got != "FLASH=success:User Mahir created" {
+		t.Fatalf("expected flash message, got %q", got)
+	}
+	cleared := w.Result().Cookies()
+	if len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Fatalf("expected flash cookie to be cleared, got %v", cleared)
+	}
+}
+
+func TestFlash_RejectsTamperedCookie(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.sessionKey = []byte("0123456789abcdef0123456789abcdef")
+
+	w := httptest.NewRecorder()
+	api.setFlash(w, httptest.NewRequest(http.MethodGet, "/", nil), Flash{Kind: "success", Message: "hi"})
+	c := w.Result().Cookies()[0]
+
+	other := newTestAPI(&fakeUserRepo{})
+	other.sessionKey = []byte("another key, another key, another")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(c)
+	if f := other.popFlash(httptest.NewRecorder(), req); f != nil {
+		t.Fatalf("expected flash signed with another key to be ignored, got %+v", f)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(c)
+	if f := api.popFlash(httptest.NewRecorder(), req); f == nil || f.Message != "hi" {
+		t.Fatalf("expected flash, got %+v", f)
+	}
+}
+
+func TestDeleteUser_FlashUsesName(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir"}, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/7/delete", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.DeleteUser(w, req)
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	if f := api.popFlash(httptest.NewRecorder(), req); f == nil || f.Message != "User Mahir deleted" {
+		t.Fatalf("expected delete flash, got %+v", f)
+	}
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"errors"
+	"goapp/internal/pkg/attributes"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/i18n"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// attributesMarker is posted by forms that include the custom attributes.
+// Without it the attributes are left alone, as by the inline row editor;
+// with it a missing checkbox means false.
+const attributesMarker = "attributes"
+
+type UsersForm struct {
+	Name       string
+	Email      string
+	BirthDate  string
+	Phone      string
+	Department string
+	Title      string
+	Locale     string
+	// Tags is a comma separated list.
+	Tags string
+	// Manager is the email of the user's manager.
+	Manager    string
+	Attributes []AttributeField
+}
+
+// AttributeField is a custom attribute as shown in a form.
+type AttributeField struct {
+	attributes.Def
+	Value string
+}
+
+// InputType is the type of the attribute's input element.
+func (f AttributeField) InputType() string {
+	switch f.Type {
+	case attributes.Number:
+		return "number"
+	case attributes.Bool:
+		return "checkbox"
+	case attributes.Date:
+		return "date"
+	}
+	return "text"
+}
+
+func (f AttributeField) Checked() bool {
+	b, _ := strconv.ParseBool(f.Value)
+	return b
+}
+
+// newUserForm returns an empty form with a field per attribute in defs.
+func newUserForm(defs attributes.Defs) UsersForm {
+	form := UsersForm{}
+	for _, d := range defs {
+		form.Attributes = append(form.Attributes, AttributeField{Def: d})
+	}
+	return form
+}
+
+// userForm fills a form with u. Attributes of u that are no longer
+// defined are left out, so they are dropped when the form is saved.
+func userForm(defs attributes.Defs, u *database.User) UsersForm {
+	form := UsersForm{
+		Name:       u.Name,
+		Email:      u.Email,
+		BirthDate:  u.BirthDate.Format(time.DateOnly),
+		Phone:      u.Phone,
+		Department: u.Department,
+		Title:      u.Title,
+		Locale:     u.Locale,
+		Tags:       database.FormatTags(u.Tags),
+	}
+	for _, d := range defs {
+		form.Attributes = append(form.Attributes, AttributeField{Def: d, Value: attributes.Format(u.Attributes[d.Name])})
+	}
+	return form
+}
+
+// update sets the fields posted in values, keeping the others. Attribute
+// inputs are named "attr." followed by the attribute's name.
+func (f *UsersForm) update(values url.Values) {
+	for name, dst := range map[string]*string{
+		"name":       &f.Name,
+		"email":      &f.Email,
+		"birth_date": &f.BirthDate,
+		"phone":      &f.Phone,
+		"department": &f.Department,
+		"title":      &f.Title,
+		"locale":     &f.Locale,
+		"tags":       &f.Tags,
+		"manager":    &f.Manager,
+	} {
+		if _, ok := values[name]; ok {
+			*dst = values.Get(name)
+		}
+	}
+
+	if !values.Has(attributesMarker) {
+		return
+	}
+	for i := range f.Attributes {
+		a := &f.Attributes[i]
+		a.Value = values.Get("attr." + a.Name)
+		if a.Type == attributes.Bool && a.Value == "" {
+			a.Value = "false"
+		}
+	}
+}
+
+var validationMessages = map[error]string{
+	database.ErrNameEmailRequired: "validation.name_email_required",
+	database.ErrInvalidEmail:      "validation.invalid_email",
+	database.ErrBirthDateRequired: "validation.birth_date_required",
+	database.ErrInvalidBirthDate:  "validation.birth_date_range",
+	database.ErrInvalidPhone:      "validation.invalid_phone",
+	database.ErrInvalidLocale:     "validation.invalid_locale",
+	database.ErrFieldTooLong:      "validation.field_too_long",
+	database.ErrInvalidTag:        "validation.invalid_tag",
+	database.ErrManagerNotFound:   "validation.manager_not_found",
+	database.ErrManagerCycle:      "validation.manager_cycle",
+}
+
+var attributeMessages = map[error]string{
+	attributes.ErrRequired: "validation.attribute_required",
+	attributes.ErrInvalid:  "validation.attribute_invalid",
+}
+
+// messageKey returns the key of the message for err, which may wrap one of
+// the errors in messages. Other errors get fallback rather than an empty
+// message, which callers would take for success.
+func messageKey(messages map[error]string, err error, fallback string) string {
+	for target, key := range messages {
+		if errors.Is(err, target) {
+			return key
+		}
+	}
+	return fallback
+}
+
+func validateUserInput(l *i18n.Localizer, form UsersForm) (*database.User, string) {
+	var birthDate time.Time
+	var dateErr error
+	if form.BirthDate != "" {
+		birthDate, dateErr = database.ParseDate(form.BirthDate)
+	}
+	u := &database.User{
+		Name:       form.Name,
+		Email:      form.Email,
+		BirthDate:  birthDate,
+		Phone:      form.Phone,
+		Department: form.Department,
+		Title:      form.Title,
+		Locale:     form.Locale,
+	}
+
+	// Problems with name and email are reported before a malformed date.
+	if err := u.Validate(); err != nil && (dateErr == nil || !errors.Is(err, database.ErrBirthDateRequired)) {
+		return nil, l.T(messageKey(validationMessages, err, "validation.invalid_input"))
+	}
+	if dateErr != nil {
+		return nil, l.T("validation.birth_date_invalid")
+	}
+	tags, err := database.ParseTags(form.Tags)
+	if err != nil {
+		return nil, l.T("validation.invalid_tag")
+	}
+	u.Tags = tags
+
+	defs := make(attributes.Defs, 0, len(form.Attributes))
+	values := make(map[string]any, len(form.Attributes))
+	for _, a := range form.Attributes {
+		defs = append(defs, a.Def)
+		values[a.Name] = a.Value
+	}
+	attrs, err := defs.Normalize(values)
+	if err != nil {
+		var attrErr *attributes.Error
+		if !errors.As(err, &attrErr) {
+			return nil, err.Error()
+		}
+		d, _ := defs.Lookup(attrErr.Name)
+		return nil, l.T(messageKey(attributeMessages, attrErr.Err, "validation.attribute_invalid"), d.DisplayLabel())
+	}
+	u.Attributes = attrs
+
+	return u, ""
+}
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/i18n"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type GroupForm struct {
+	Name        string
+	Description string
+}
+
+// GroupsPageData is the data of the groups page.
+type GroupsPageData struct {
+	Groups []database.Group
+	Form   GroupForm
+	Search string
+	Error  string
+	Flash  *Flash
+	L      *i18n.Localizer
+}
+
+// GroupPageData is the data of the group page, which lists the members and
+// has the form adding them.
+type GroupPageData struct {
+	Group   *database.Group
+	Members []database.Member
+	// MemberEmail and MemberRole refill the add member form after an error.
+	MemberEmail string
+	MemberRole  string
+	Error       string
+	Flash       *Flash
+	L           *i18n.Localizer
+}
+
+// GroupEditPageData is the data of the group edit and delete pages.
+type GroupEditPageData struct {
+	Group *database.Group
+	Form  GroupForm
+	Error string
+	Flash *Flash
+	L     *i18n.Localizer
+}
+
+func (d GroupsPageData) withLocalizer(l *i18n.Localizer) any {
+	d.L = l
+	return d
+}
+
+func (d GroupPageData) withLocalizer(l *i18n.Localizer) any {
+	d.L = l
+	return d
+}
+
+func (d GroupEditPageData) withLocalizer(l *i18n.Localizer) any {
+	d.L = l
+	return d
+}
+
+var groupValidationMessages = map[error]string{
+	database.ErrGroupNameRequired: "validation.group_name_required",
+	database.ErrGroupNameTooLong:  "validation.group_name_too_long",
+	database.ErrDescriptionLong:   "validation.description_too_long",
+}
+
+func groupFormFromValues(r *http.Request) GroupForm {
+	return GroupForm{
+		Name:        strings.TrimSpace(r.PostForm.Get("name")),
+		Description: strings.TrimSpace(r.PostForm.Get("description")),
+	}
+}
+
+func validateGroupInput(l *i18n.Localizer, form GroupForm) (*database.Group, string) {
+	g := &database.Group{Name: form.Name, Description: form.Description}
+	if err := g.Validate(); err != nil {
+		return nil, l.T(messageKey(groupValidationMessages, err, "validation.invalid_input"))
+	}
+	return g, ""
+}
+
+// groupFromPath loads the group named by the {id} route variable. When
+// that fails it returns the status and message to show instead.
+func (api *Api) groupFromPath(r *http.Request) (*database.Group, int, string) {
+	l := i18n.FromContext(r.Context())
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, http.StatusBadRequest, l.T("error.invalid_id")
+	}
+
+	group, err := api.groups.GetGroupByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, database.ErrGroupNotFound) {
+			return nil, http.StatusNotFound, l.T("error.group_not_found")
+		}
+		api.logger.ErrorContext(r.Context(), "failed to fetch group", "id", id, "error", err)
+		return nil, http.StatusInternalServerError, l.T("error.fetch_group")
+	}
+	return group, http.StatusOK, ""
+}
+
+// GetGroups lists the groups, optionally searched by name, with the form
+// creating one.
+func (api *Api) GetGroups(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	search := r.URL.Query().Get("q")
+
+	groups, err := api.groups.GetGroups(r.Context(), database.GroupQuery{Search: search})
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch groups", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		api.renderTemplate(w, r, "groups.html", GroupsPageData{Search: search, Error: l.T("error.fetch_groups")})
+		return
+	}
+
+	api.renderTemplate(w, r, "groups.html", GroupsPageData{
+		Groups: groups,
+		Search: search,
+		Flash:  api.popFlash(w, r),
+	})
+}
+
+func (api *Api) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+
+	render := func(status int, msg string, form GroupForm) {
+		groups, err := api.groups.GetGroups(r.Context(), database.GroupQuery{})
+		if err != nil {
+			api.logger.ErrorContext(r.Context(), "failed to fetch groups", "error", err)
+		}
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "groups.html", GroupsPageData{Groups: groups, Form: form, Error: msg})
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+
+	form := groupFormFromValues(r)
+	g, msg := validateGroupInput(l, form)
+	if msg != "" {
+		render(http.StatusBadRequest, msg, form)
+		return
+	}
+
+	if err := api.groups.CreateGroup(r.Context(), g); err != nil {
+		if database.IsDuplicate(err) {
+			render(http.StatusBadRequest, l.T("error.group_exists"), form)
+			return
+		}
+
+		api.logger.ErrorContext(r.Context(), "failed to create group", "error", err)
+		render(http.StatusInternalServerError, l.T("error.create_group"), form)
+		return
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.group_created", g.Name)})
+	http.Redirect(w, r, fmt.Sprintf("/groups/%d", g.ID), http.StatusSeeOther)
+}
+
+// GetGroup shows a group with its members.
+func (api *Api) GetGroup(w http.ResponseWriter, r *http.Request) {
+	group, status, msg := api.groupFromPath(r)
+	if group == nil {
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "group.html", GroupPageData{Error: msg})
+		return
+	}
+
+	data := GroupPageData{Group: group, MemberRole: database.RoleMember, Flash: api.popFlash(w, r)}
+	api.renderGroup(w, r, http.StatusOK, data)
+}
+
+// renderGroup renders the group page of data.Group, loading its members.
+func (api *Api) renderGroup(w http.ResponseWriter, r *http.Request, status int, data GroupPageData) {
+	members, err := api.groups.GetGroupMembers(r.Context(), data.Group.ID)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch group members", "id", data.Group.ID, "error", err)
+		status = http.StatusInternalServerError
+		data.Error = i18n.FromContext(r.Context()).T("error.fetch_members")
+	}
+	data.Members = members
+
+	w.WriteHeader(status)
+	api.renderTemplate(w, r, "group.html", data)
+}
+
+func (api *Api) EditGroupForm(w http.ResponseWriter, r *http.Request) {
+	group, status, msg := api.groupFromPath(r)
+	if group == nil {
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "group-edit.html", GroupEditPageData{Error: msg})
+		return
+	}
+
+	api.renderTemplate(w, r, "group-edit.html", GroupEditPageData{
+		Group: group,
+		Form:  GroupForm{Name: group.Name, Description: group.Description},
+	})
+}
+
+func (api *Api) EditGroup(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	existing, status, msg := api.groupFromPath(r)
+	if existing == nil {
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "group-edit.html", GroupEditPageData{Error: msg})
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+
+	form := groupFormFromValues(r)
+	render := func(status int, msg string) {
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "group-edit.html", GroupEditPageData{Group: existing, Form: form, Error: msg})
+	}
+
+	g, msg := validateGroupInput(l, form)
+	if msg != "" {
+		render(http.StatusBadRequest, msg)
+		return
+	}
+	g.ID = existing.ID
+
+	if err := api.groups.UpdateGroup(r.Context(), g); err != nil {
+		if database.IsDuplicate(err) {
+			render(http.StatusBadRequest, l.T("error.group_exists"))
+			return
+		}
+
+		if errors.Is(err, database.ErrGroupNotFound) {
+			render(http.StatusNotFound, l.T("error.group_not_found"))
+			return
+		}
+
+		api.logger.ErrorContext(r.Context(), "failed to update group", "id", g.ID, "error", err)
+		render(http.StatusInternalServerError, l.T("error.update_group"))
+		return
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.group_updated", g.Name)})
+	http.Redirect(w, r, fmt.Sprintf("/groups/%d", g.ID), http.StatusSeeOther)
+}
+
+// ConfirmDeleteGroup asks before deleting a group.
+func (api *Api) ConfirmDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	group, status, msg := api.groupFromPath(r)
+	if group == nil {
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "group-delete.html", GroupEditPageData{Error: msg})
+		return
+	}
+
+	api.renderTemplate(w, r, "group-delete.html", GroupEditPageData{Group: group})
+}
+
+// DeleteGroup deletes a group. Its members are only removed from it.
+func (api *Api) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	group, status, msg := api.groupFromPath(r)
+	if group == nil {
+		http.Error(w, msg, status)
+		return
+	}
+
+	if err := api.groups.DeleteGroup(r.Context(), group.ID); err != nil {
+		if errors.Is(err, database.ErrGroupNotFound) {
+			http.Error(w, l.T("error.group_not_found"), http.StatusNotFound)
+			return
+		}
+
+		api.logger.ErrorContext(r.Context(), "failed to delete group", "id", group.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.group_deleted", group.Name)})
+	http.Redirect(w, r, "/groups", http.StatusSeeOther)
+}
+
+// SetGroupMember adds the user with the posted email to a group with the
+// posted role, or changes the role of a member.
+func (api *Api) SetGroupMember(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	group, status, msg := api.groupFromPath(r)
+	if group == nil {
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "group.html", GroupPageData{Error: msg})
+		return
+	}
+
+	if !parseForm(w, r) {
+		return
+	}
+
+	email := strings.TrimSpace(r.PostForm.Get("email"))
+	role := r.PostForm.Get("role")
+	if role == "" {
+		role = database.RoleMember
+	}
+	render := func(status int, msg string) {
+		api.renderGroup(w, r, status, GroupPageData{Group: group, MemberEmail: email, MemberRole: role, Error: msg})
+	}
+
+	if !database.ValidRole(role) {
+		render(http.StatusBadRequest, l.T("validation.invalid_role"))
+		return
+	}
+	if email == "" {
+		render(http.StatusBadRequest, l.T("validation.member_email_required"))
+		return
+	}
+
+	user, err := api.db.GetUserByEmail(r.Context(), email)
+	if err == nil {
+		err = api.groups.SetGroupMember(r.Context(), group.ID, user.ID, role)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrUserNotFound):
+			render(http.StatusBadRequest, l.T("error.member_not_user", email))
+		case errors.Is(err, database.ErrGroupNotFound):
+			render(http.StatusNotFound, l.T("error.group_not_found"))
+		default:
+			api.logger.ErrorContext(r.Context(), "failed to set group member", "id", group.ID, "email", email, "error", err)
+			render(http.StatusInternalServerError, l.T("error.set_member"))
+		}
+		return
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.member_set", user.Name, l.T("role."+role))})
+	http.Redirect(w, r, fmt.Sprintf("/groups/%d", group.ID), http.StatusSeeOther)
+}
+
+// RemoveGroupMember removes the user named by the {user} route variable
+// from a group.
+func (api *Api) RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	vars := mux.Vars(r)
+	groupID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, l.T("error.invalid_id"), http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.ParseInt(vars["user"], 10, 64)
+	if err != nil {
+		http.Error(w, l.T("error.invalid_id"), http.StatusBadRequest)
+		return
+	}
+
+	// Look the name up only for the flash message.
+	label := fmt.Sprintf("#%d", userID)
+	if u, err := api.db.GetUserByID(r.Context(), userID); err == nil {
+		label = u.Name
+	}
+
+	if err := api.groups.RemoveGroupMember(r.Context(), groupID, userID); err != nil {
+		if errors.Is(err, database.ErrMemberNotFound) {
+			http.Error(w, l.T("error.member_not_found"), http.StatusNotFound)
+			return
+		}
+
+		api.logger.ErrorContext(r.Context(), "failed to remove group member", "id", groupID, "user", userID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.member_removed", label)})
+	http.Redirect(w, r, fmt.Sprintf("/groups/%d", groupID), http.StatusSeeOther)
+}
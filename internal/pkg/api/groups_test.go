@@ -0,0 +1,319 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"goapp/internal/pkg/database"
+
+	mysql "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+type fakeGroupRepo struct {
+	getGroupsFn     func(ctx context.Context, q database.GroupQuery) ([]database.Group, error)
+	getGroupByIDFn  func(ctx context.Context, id int64) (*database.Group, error)
+	createGroupFn   func(ctx context.Context, g *database.Group) error
+	updateGroupFn   func(ctx context.Context, g *database.Group) error
+	deleteGroupFn   func(ctx context.Context, id int64) error
+	getMembersFn    func(ctx context.Context, groupID int64) ([]database.Member, error)
+	getUserGroupsFn func(ctx context.Context, userID int64) ([]database.Membership, error)
+	setMemberFn     func(ctx context.Context, groupID, userID int64, role string) error
+	removeMemberFn  func(ctx context.Context, groupID, userID int64) error
+}
+
+func (f *fakeGroupRepo) GetGroups(ctx context.Context, q database.GroupQuery) ([]database.Group, error) {
+	if f.getGroupsFn != nil {
+		return f.getGroupsFn(ctx, q)
+	}
+	return []database.Group{}, nil
+}
+
+func (f *fakeGroupRepo) GetGroupByID(ctx context.Context, id int64) (*database.Group, error) {
+	if f.getGroupByIDFn != nil {
+		return f.getGroupByIDFn(ctx, id)
+	}
+	return nil, database.ErrGroupNotFound
+}
+
+func (f *fakeGroupRepo) CreateGroup(ctx context.Context, g *database.Group) error {
+	if f.createGroupFn != nil {
+		return f.createGroupFn(ctx, g)
+	}
+	return nil
+}
+
+func (f *fakeGroupRepo) UpdateGroup(ctx context.Context, g *database.Group) error {
+	if f.updateGroupFn != nil {
+		return f.updateGroupFn(ctx, g)
+	}
+	return nil
+}
+
+func (f *fakeGroupRepo) DeleteGroup(ctx context.Context, id int64) error {
+	if f.deleteGroupFn != nil {
+		return f.deleteGroupFn(ctx, id)
+	}
+	return nil
+}
+
+func (f *fakeGroupRepo) GetGroupMembers(ctx context.Context, groupID int64) ([]database.Member, error) {
+	if f.getMembersFn != nil {
+		return f.getMembersFn(ctx, groupID)
+	}
+	return []database.Member{}, nil
+}
+
+func (f *fakeGroupRepo) GetUserGroups(ctx context.Context, userID int64) ([]database.Membership, error) {
+	if f.getUserGroupsFn != nil {
+		return f.getUserGroupsFn(ctx, userID)
+	}
+	return []database.Membership{}, nil
+}
+
+func (f *fakeGroupRepo) SetGroupMember(ctx context.Context, groupID, userID int64, role string) error {
+	if f.setMemberFn != nil {
+		return f.setMemberFn(ctx, groupID, userID, role)
+	}
+	return nil
+}
+
+func (f *fakeGroupRepo) RemoveGroupMember(ctx context.Context, groupID, userID int64) error {
+	if f.removeMemberFn != nil {
+		return f.removeMemberFn(ctx, groupID, userID)
+	}
+	return nil
+}
+
+func opsGroup(ctx context.Context, id int64) (*database.Group, error) {
+	return &database.Group{ID: id, Name: "Ops"}, nil
+}
+
+func TestCreateGroup_Validation(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+
+	req := httptest.NewRequest(http.MethodPost, "/groups", strings.NewReader(url.Values{"name": {"  "}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	api.CreateGroup(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "ERROR=group name is required") {
+		t.Fatalf("expected validation error, got %q", w.Body.String())
+	}
+}
+
+func TestCreateGroup_DuplicateName(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.groups = &fakeGroupRepo{
+		createGroupFn: func(ctx context.Context, g *database.Group) error {
+			return &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/groups", strings.NewReader(url.Values{"name": {"Ops"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	api.CreateGroup(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if got := w.Body.String(); got != "GROUPS=0 NAME=Ops ERROR=group name already exists" {
+		t.Fatalf("unexpected body %q", got)
+	}
+}
+
+func TestCreateGroup_RedirectsToGroup(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.groups = &fakeGroupRepo{
+		createGroupFn: func(ctx context.Context, g *database.Group) error {
+			g.ID = 4
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/groups", strings.NewReader(url.Values{"name": {"Ops"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	api.CreateGroup(w, req)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/groups/4" {
+		t.Fatalf("expected redirect to /groups/4, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestGetGroup_ListsMembers(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.groups = &fakeGroupRepo{
+		getGroupByIDFn: opsGroup,
+		getMembersFn: func(ctx context.Context, groupID int64) ([]database.Member, error) {
+			return []database.Member{{User: database.User{ID: 7}, Role: database.RoleLead}, {User: database.User{ID: 8}, Role: database.RoleMember}}, nil
+		},
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/groups/3", nil), map[string]string{"id": "3"})
+	w := httptest.NewRecorder()
+	api.GetGroup(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Body.String(); got != "GROUP=Ops MEMBERS=2 EMAIL= ERROR=" {
+		t.Fatalf("unexpected body %q", got)
+	}
+}
+
+func TestGetGroup_NotFound(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/groups/3", nil), map[string]string{"id": "3"})
+	w := httptest.NewRecorder()
+	api.GetGroup(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "ERROR=group not found") {
+		t.Fatalf("expected group not found, got %q", w.Body.String())
+	}
+}
+
+func TestSetGroupMember_ByEmail(t *testing.T) {
+	var gotGroup, gotUser int64
+	var gotRole string
+	api := newTestAPI(&fakeUserRepo{
+		getByEmailFn: func(ctx context.Context, email string) (*database.User, error) {
+			return &database.User{ID: 7, Name: "Mahir", Email: email}, nil
+		},
+	})
+	api.groups = &fakeGroupRepo{
+		getGroupByIDFn: opsGroup,
+		setMemberFn: func(ctx context.Context, groupID, userID int64, role string) error {
+			gotGroup, gotUser, gotRole = groupID, userID, role
+			return nil
+		},
+	}
+
+	form := url.Values{"email": {"mahir@test.com"}, "role": {"lead"}}
+	req := httptest.NewRequest(http.MethodPost, "/groups/3/members", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	w := httptest.NewRecorder()
+	api.SetGroupMember(w, req)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/groups/3" {
+		t.Fatalf("expected redirect to /groups/3, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if gotGroup != 3 || gotUser != 7 || gotRole != database.RoleLead {
+		t.Fatalf("unexpected membership %d %d %q", gotGroup, gotUser, gotRole)
+	}
+}
+
+func TestSetGroupMember_Errors(t *testing.T) {
+	tests := []struct {
+		form url.Values
+		want string
+	}{
+		{url.Values{"email": {"nobody@test.com"}}, "EMAIL=nobody@test.com ERROR=no user with email nobody@test.com"},
+		{url.Values{"email": {"mahir@test.com"}, "role": {"owner"}}, "EMAIL=mahir@test.com ERROR=invalid role"},
+		{url.Values{}, "EMAIL= ERROR=email is required"},
+	}
+	for _, tt := range tests {
+		api := newTestAPI(&fakeUserRepo{})
+		api.groups = &fakeGroupRepo{getGroupByIDFn: opsGroup}
+
+		req := httptest.NewRequest(http.MethodPost, "/groups/3/members", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = mux.SetURLVars(req, map[string]string{"id": "3"})
+		w := httptest.NewRecorder()
+		api.SetGroupMember(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%v: expected 400, got %d", tt.form, w.Code)
+		}
+		if !strings.HasSuffix(w.Body.String(), tt.want) {
+			t.Fatalf("%v: expected %q, got %q", tt.form, tt.want, w.Body.String())
+		}
+	}
+}
+
+func TestRemoveGroupMember_NotMember(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.groups = &fakeGroupRepo{
+		removeMemberFn: func(ctx context.Context, groupID, userID int64) error {
+			return database.ErrMemberNotFound
+		},
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/groups/3/members/7/delete", nil), map[string]string{"id": "3", "user": "7"})
+	w := httptest.NewRecorder()
+	api.RemoveGroupMember(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestGetUsers_GroupFilter(t *testing.T) {
+	var got database.UserQuery
+	api := newTestAPI(&fakeUserRepo{
+		getUsersFn: func(ctx context.Context, q database.UserQuery) ([]database.User, error) {
+			got = q
+			return []database.User{}, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	api.GetUsers(w, httptest.NewRequest(http.MethodGet, "/users?group=3", nil))
+	if w.Code != http.StatusOK || got.GroupID != 3 {
+		t.Fatalf("expected group 3 to be filtered, got %d %+v", w.Code, got)
+	}
+
+	w = httptest.NewRecorder()
+	api.GetUsers(w, httptest.NewRequest(http.MethodGet, "/users?group=ops", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "ERROR=invalid group") {
+		t.Fatalf("expected invalid group, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestGetGroupJSON(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.groups = &fakeGroupRepo{
+		getGroupByIDFn: opsGroup,
+		getMembersFn: func(ctx context.Context, groupID int64) ([]database.Member, error) {
+			return []database.Member{{User: database.User{ID: 7, Name: "Mahir"}, Role: database.RoleLead}}, nil
+		},
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/groups/3", nil), map[string]string{"id": "3"})
+	w := httptest.NewRecorder()
+	api.GetGroupJSON(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		ID      int64  `json:"id"`
+		Name    string `json:"name"`
+		Members []struct {
+			User struct {
+				ID int64 `json:"id"`
+			} `json:"user"`
+			Role string `json:"role"`
+		} `json:"members"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("expected JSON body, got %v", err)
+	}
+	if resp.ID != 3 || resp.Name != "Ops" || len(resp.Members) != 1 || resp.Members[0].User.ID != 7 || resp.Members[0].Role != "lead" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
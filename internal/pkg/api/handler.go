@@ -2,26 +2,43 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"goapp/internal/pkg/database"
-	"log"
+	"goapp/internal/pkg/i18n"
 	"net/http"
-	"regexp"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 )
 
-type UsersForm struct {
-	Name  string
-	Email string
-	Age   string
-}
-
 type UsersPageData struct {
 	Users []database.User
 	Form  UsersForm
 	Error string
+	Flash *Flash
+	L     *i18n.Localizer
+
+	// The list parameters, as given in the request.
+	Search        string
+	Sort          string
+	CreatedAfter  string
+	CreatedBefore string
+	BornAfter     string
+	BornBefore    string
+	Group         string
+	Tags          []string
+
+	// Groups and AllTags are the options of the group and tag filters.
+	Groups  []database.Group
+	AllTags []database.Tag
+
+	// LiveSearch makes the search form search as the user types. It is the
+	// live_search feature.
+	LiveSearch bool
 
 	Page     int
 	Limit    int
@@ -29,270 +46,588 @@ type UsersPageData struct {
 	NextPage int
 }
 
+// listURL links to the users page with the page's list parameters and the
+// given page and sort.
+func (d UsersPageData) listURL(page int, sort string) string {
+	v := url.Values{}
+	v.Set("page", strconv.Itoa(page))
+	v.Set("limit", strconv.Itoa(d.Limit))
+	for key, value := range map[string]string{
+		"q":              d.Search,
+		"sort":           sort,
+		"created_after":  d.CreatedAfter,
+		"created_before": d.CreatedBefore,
+		"born_after":     d.BornAfter,
+		"born_before":    d.BornBefore,
+		"group":          d.Group,
+	} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	for _, t := range d.Tags {
+		v.Add("tag", t)
+	}
+	return "/users?" + v.Encode()
+}
+
+// PageURL links to another page of the same list.
+func (d UsersPageData) PageURL(page int) string {
+	return d.listURL(page, d.Sort)
+}
+
+// GroupSelected reports whether the list is filtered by the group id.
+func (d UsersPageData) GroupSelected(id int64) bool {
+	return d.Group == strconv.FormatInt(id, 10)
+}
+
+// TagSelected reports whether the list is filtered by the tag.
+func (d UsersPageData) TagSelected(name string) bool {
+	return slices.Contains(d.Tags, name)
+}
+
+// SortURL links to the first page sorted by field, descending if the list
+// is already sorted by it in ascending order.
+func (d UsersPageData) SortURL(field string) string {
+	if d.Sort == field {
+		return d.listURL(1, "-"+field)
+	}
+	return d.listURL(1, field)
+}
+
+// SortMark shows whether the list is sorted by field and in which order.
+func (d UsersPageData) SortMark(field string) string {
+	switch d.Sort {
+	case field:
+		return "▲"
+	case "-" + field:
+		return "▼"
+	}
+	return ""
+}
+
 type EditPageData struct {
+	User *database.User
+	Form UsersForm
+	// AvatarLimit is the largest avatar upload, formatted for the hint.
+	AvatarLimit string
+	Error       string
+	Flash       *Flash
+	L           *i18n.Localizer
+}
+
+// UserPageData is the data of the user detail page.
+type UserPageData struct {
+	User *database.User
+	// Attributes are the user's custom attributes in the configured order.
+	Attributes []AttributeField
+	Groups     []database.Membership
+	// Managers is the management chain from the top of the organization
+	// down to the user's manager, and Reports the users below the user.
+	Managers []database.User
+	Reports  []*OrgNode
+	History  []database.AuditEntry
+	Error    string
+	Flash    *Flash
+	L        *i18n.Localizer
+}
+
+// UserRowData is the data of the user-row and user-row-edit fragments.
+type UserRowData struct {
 	User  *database.User
+	Form  UsersForm
 	Error string
+	L     *i18n.Localizer
 }
 
-var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+// localized is implemented by page data that carries the request's
+// Localizer for the templates; renderTemplate fills it in.
+type localized interface {
+	withLocalizer(l *i18n.Localizer) any
+}
 
-func validateUserInput(name, email, ageStr string) (*database.User, string) {
-	if name == "" || email == "" {
-		return nil, "name and email are required"
-	}
+func (d UsersPageData) withLocalizer(l *i18n.Localizer) any {
+	d.L = l
+	return d
+}
 
-	if !emailRegex.MatchString(email) {
-		return nil, "invalid email format"
-	}
+func (d EditPageData) withLocalizer(l *i18n.Localizer) any {
+	d.L = l
+	return d
+}
 
-	age, err := strconv.Atoi(ageStr)
-	if err != nil {
-		return nil, "age must be a number"
-	}
-	if age <= 0 {
-		return nil, "age must be greater than 0"
-	}
+func (d UserPageData) withLocalizer(l *i18n.Localizer) any {
+	d.L = l
+	return d
+}
 
-	return &database.User{
-		Name:  name,
-		Email: email,
-		Age:   age,
-	}, ""
+func (d UserRowData) withLocalizer(l *i18n.Localizer) any {
+	d.L = l
+	return d
 }
 
-func (api *Api) GetUsers(w http.ResponseWriter, r *http.Request) {
-	page := 1
-	limit := 10
+// isPartial reports whether r asks for just a fragment of the page, as the
+// users page script does with the HX-Request header. Responses differ by
+// the header, so it is added to Vary.
+func isPartial(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "HX-Request")
+	return r.Header.Get("HX-Request") == "true"
+}
 
-	if p := r.URL.Query().Get("page"); p != "" {
+// usersTemplate names the template that renders UsersPageData.
+func usersTemplate(partial bool) string {
+	if partial {
+		return "users-content"
+	}
+	return "users.html"
+}
+
+// parseUserQuery reads the list parameters shared by the users page and
+// the JSON API. On error it also returns the message key of the problem,
+// with the query and page holding what was read so far.
+func (api *Api) parseUserQuery(v url.Values) (q database.UserQuery, page int, errKey string) {
+	defaultLimit, maxLimit := api.pageLimits()
+	page = 1
+	q.Limit = defaultLimit
+	q.Search = v.Get("q")
+	q.Sort = v.Get("sort")
+
+	if p := v.Get("page"); p != "" {
 		parsed, err := strconv.Atoi(p)
 		if err != nil || parsed < 1 {
-			w.WriteHeader(http.StatusBadRequest)
-			api.renderTemplate(w, "users.html", UsersPageData{
-				Error: "invalid page",
-				Page:  1,
-				Limit: limit,
-			})
-			return
+			return q, page, "error.invalid_page"
 		}
 		page = parsed
 	}
 
-	if l := r.URL.Query().Get("limit"); l != "" {
+	if l := v.Get("limit"); l != "" {
 		parsed, err := strconv.Atoi(l)
 		if err != nil || parsed < 1 {
-			w.WriteHeader(http.StatusBadRequest)
-			api.renderTemplate(w, "users.html", UsersPageData{
-				Error: "invalid limit",
-				Page:  page,
-				Limit: 10,
-			})
-			return
+			return q, page, "error.invalid_limit"
 		}
-		if parsed > 100 {
-			parsed = 100
+		q.Limit = min(parsed, maxLimit)
+	}
+	q.Offset = (page - 1) * q.Limit
+
+	if err := q.Validate(); err != nil {
+		return q, page, "error.invalid_sort"
+	}
+
+	for param, dst := range map[string]*time.Time{
+		"created_after":  &q.CreatedAfter,
+		"created_before": &q.CreatedBefore,
+		"born_after":     &q.BornAfter,
+		"born_before":    &q.BornBefore,
+	} {
+		if s := v.Get(param); s != "" {
+			t, err := database.ParseTime(s)
+			if err != nil {
+				return q, page, "error.invalid_date"
+			}
+			*dst = t
 		}
-		limit = parsed
 	}
 
-	offset := (page - 1) * limit
+	if g := v.Get("group"); g != "" {
+		id, err := strconv.ParseInt(g, 10, 64)
+		if err != nil || id < 1 {
+			return q, page, "error.invalid_group"
+		}
+		q.GroupID = id
+	}
 
-	users, err := api.db.GetUsers(r.Context(), limit, offset)
+	for _, t := range v["tag"] {
+		tags, err := database.NormalizeTags([]string{t})
+		if err != nil {
+			return q, page, "error.invalid_tag"
+		}
+		q.Tags = append(q.Tags, tags...)
+	}
+
+	return q, page, ""
+}
+
+func (api *Api) GetUsers(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	partial := isPartial(w, r)
+	tmpl := usersTemplate(partial)
+	v := r.URL.Query()
+
+	q, page, errKey := api.parseUserQuery(v)
+	data := UsersPageData{
+		Form:          newUserForm(api.attributeDefs()),
+		Search:        q.Search,
+		Sort:          q.Sort,
+		CreatedAfter:  v.Get("created_after"),
+		CreatedBefore: v.Get("created_before"),
+		BornAfter:     v.Get("born_after"),
+		BornBefore:    v.Get("born_before"),
+		Group:         v.Get("group"),
+		Tags:          q.Tags,
+		Page:          page,
+		Limit:         q.Limit,
+	}
+	// The group and tag filters are part of the search form, which partial
+	// requests don't replace. The page is still useful without them.
+	if !partial {
+		data.LiveSearch = api.featureEnabled("live_search")
+		groups, err := api.groups.GetGroups(r.Context(), database.GroupQuery{})
+		if err != nil {
+			api.logger.ErrorContext(r.Context(), "failed to fetch groups", "error", err)
+		}
+		data.Groups = groups
+		tags, err := api.db.GetTags(r.Context())
+		if err != nil {
+			api.logger.ErrorContext(r.Context(), "failed to fetch tags", "error", err)
+		}
+		data.AllTags = tags
+	}
+	if errKey != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		data.Error = l.T(errKey)
+		api.renderTemplate(w, r, tmpl, data)
+		return
+	}
+
+	users, err := api.db.GetUsers(r.Context(), q)
 	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch users", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
-		api.renderTemplate(w, "users.html", UsersPageData{
-			Error: "failed to fetch users",
-			Page:  page,
-			Limit: limit,
-		})
+		data.Error = l.T("error.fetch_users")
+		api.renderTemplate(w, r, tmpl, data)
 		return
 	}
 
-	prevPage := 0
 	if page > 1 {
-		prevPage = page - 1
+		data.PrevPage = page - 1
 	}
-	nextPage := page + 1
-
-	api.renderTemplate(w, "users.html", UsersPageData{
-		Users:    users,
-		Page:     page,
-		Limit:    limit,
-		PrevPage: prevPage,
-		NextPage: nextPage,
-	})
+	data.NextPage = page + 1
+	data.Users = users
+	data.Flash = api.popFlash(w, r)
+	api.renderTemplate(w, r, tmpl, data)
 }
 
-func (api *Api) GetUser(w http.ResponseWriter, r *http.Request) {
-	idStr := mux.Vars(r)["id"]
-	id, err := strconv.ParseInt(idStr, 10, 64)
+// userFromPath loads the user named by the {id} route variable. When that
+// fails it returns the status and message to show instead.
+func (api *Api) userFromPath(r *http.Request) (*database.User, int, string) {
+	l := i18n.FromContext(r.Context())
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		api.renderTemplate(w, "edit.html", EditPageData{
-			Error: "invalid id",
-		})
-		return
+		return nil, http.StatusBadRequest, l.T("error.invalid_id")
 	}
 
 	user, err := api.db.GetUserByID(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, database.ErrUserNotFound) {
-			w.WriteHeader(http.StatusNotFound)
-			api.renderTemplate(w, "edit.html", EditPageData{
-				Error: "user not found",
-			})
+			return nil, http.StatusNotFound, l.T("error.user_not_found")
+		}
+		api.logger.ErrorContext(r.Context(), "failed to fetch user", "id", id, "error", err)
+		return nil, http.StatusInternalServerError, l.T("error.fetch_user")
+	}
+	return user, http.StatusOK, ""
+}
+
+// GetUser shows a user with its groups and change history.
+func (api *Api) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "user.html", UserPageData{Error: msg})
+		return
+	}
+
+	// The page is still useful without the groups, hierarchy and history.
+	groups, err := api.groups.GetUserGroups(r.Context(), user.ID)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch user groups", "id", user.ID, "error", err)
+	}
+	managers, err := api.db.GetManagementChain(r.Context(), user.ID)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch management chain", "id", user.ID, "error", err)
+	}
+	slices.Reverse(managers)
+	reports, err := api.db.GetReports(r.Context(), user.ID, 0)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch reports", "id", user.ID, "error", err)
+	}
+	history, err := api.db.GetUserAudit(r.Context(), user.ID)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch audit log", "id", user.ID, "error", err)
+	}
+
+	api.renderTemplate(w, r, "user.html", UserPageData{
+		User:       user,
+		Attributes: userForm(api.attributeDefs(), user).Attributes,
+		Groups:     groups,
+		Managers:   managers,
+		Reports:    buildOrgTree(user.ID, reports),
+		History:    history,
+		Flash:      api.popFlash(w, r),
+	})
+}
+
+// EditUserForm shows the edit form, or an editable table row to partial
+// requests.
+func (api *Api) EditUserForm(w http.ResponseWriter, r *http.Request) {
+	partial := isPartial(w, r)
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		if partial {
+			http.Error(w, msg, status)
 			return
 		}
-		log.Print(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		api.renderTemplate(w, "edit.html", EditPageData{
-			Error: "failed to fetch user",
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "edit.html", EditPageData{Error: msg})
+		return
+	}
+
+	form := userForm(api.attributeDefs(), user)
+	if partial {
+		api.renderTemplate(w, r, "user-row-edit", UserRowData{
+			User: user,
+			Form: form,
 		})
 		return
 	}
 
-	api.renderTemplate(w, "edit.html", EditPageData{
-		User: user,
+	manager, err := api.managerEmail(r.Context(), user)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch manager", "id", user.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		api.renderTemplate(w, r, "edit.html", EditPageData{Error: i18n.FromContext(r.Context()).T("error.fetch_user")})
+		return
+	}
+	form.Manager = manager
+
+	api.renderTemplate(w, r, "edit.html", EditPageData{
+		User:        user,
+		Form:        form,
+		AvatarLimit: formatSize(api.avatarConfig().MaxBytes),
+		Flash:       api.popFlash(w, r),
 	})
 }
 
+// ConfirmDeleteUser asks before deleting a user, on a page of its own or,
+// for partial requests, in the user's table row.
+func (api *Api) ConfirmDeleteUser(w http.ResponseWriter, r *http.Request) {
+	partial := isPartial(w, r)
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		if partial {
+			http.Error(w, msg, status)
+			return
+		}
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "delete.html", EditPageData{Error: msg})
+		return
+	}
+
+	if partial {
+		api.renderTemplate(w, r, "user-row-delete", UserRowData{User: user})
+		return
+	}
+
+	api.renderTemplate(w, r, "delete.html", EditPageData{User: user})
+}
+
+// UserRow renders the table row of a user, for cancelling an inline edit
+// or delete.
+func (api *Api) UserRow(w http.ResponseWriter, r *http.Request) {
+	if !isPartial(w, r) {
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		http.Error(w, msg, status)
+		return
+	}
+
+	api.renderTemplate(w, r, "user-row", UserRowData{User: user})
+}
+
 func (api *Api) CreateUser(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	partial := isPartial(w, r)
 	const page = 1
-	const limit = 10
 	const offset = 0
+	limit, _ := api.pageLimits()
 
-	render := func(status int, msg string, form UsersForm) {
-		users, err := api.db.GetUsers(r.Context(), limit, offset)
+	render := func(status int, msg string, form UsersForm, flash *Flash) {
+		users, err := api.db.GetUsers(r.Context(), database.UserQuery{Limit: limit, Offset: offset})
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			api.renderTemplate(w, "users.html", UsersPageData{
-				Error:    "failed to fetch users",
-				Page:     page,
-				Limit:    limit,
-				PrevPage: 0,
-				NextPage: 2,
+			api.renderTemplate(w, r, usersTemplate(partial), UsersPageData{
+				Error:      l.T("error.fetch_users"),
+				LiveSearch: api.featureEnabled("live_search"),
+				Page:       page,
+				Limit:      limit,
+				PrevPage:   0,
+				NextPage:   2,
 			})
 			return
 		}
 
 		w.WriteHeader(status)
-		api.renderTemplate(w, "users.html", UsersPageData{
-			Users:    users,
-			Form:     form,
-			Error:    msg,
-			Page:     page,
-			Limit:    limit,
-			PrevPage: 0,
-			NextPage: 2,
+		api.renderTemplate(w, r, usersTemplate(partial), UsersPageData{
+			Users:      users,
+			Form:       form,
+			Error:      msg,
+			Flash:      flash,
+			LiveSearch: api.featureEnabled("live_search"),
+			Page:       page,
+			Limit:      limit,
+			PrevPage:   0,
+			NextPage:   2,
 		})
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+	if !parseForm(w, r) {
 		return
 	}
 
-	name := r.FormValue("name")
-	email := r.FormValue("email")
-	ageStr := r.FormValue("age")
+	defs := api.attributeDefs()
+	form := newUserForm(defs)
+	form.update(r.PostForm)
 
-	u, msg := validateUserInput(name, email, ageStr)
+	u, msg := validateUserInput(l, form)
 	if msg != "" {
-		render(http.StatusBadRequest, msg, UsersForm{
-			Name:  name,
-			Email: email,
-			Age:   ageStr,
-		})
+		render(http.StatusBadRequest, msg, form, nil)
+		return
+	}
+	if u.ManagerID, msg = api.resolveManager(r.Context(), l, form.Manager); msg != "" {
+		render(http.StatusBadRequest, msg, form, nil)
 		return
 	}
 
 	if err := api.db.CreateUser(r.Context(), u); err != nil {
-		var mysqlErr *mysql.MySQLError
-		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
-			render(http.StatusBadRequest, "email already exists", UsersForm{
-				Name:  name,
-				Email: email,
-				Age:   ageStr,
-			})
+		if database.IsDuplicate(err) {
+			render(http.StatusBadRequest, l.T("error.email_exists"), form, nil)
+			return
+		}
+		if errors.Is(err, database.ErrManagerNotFound) {
+			render(http.StatusBadRequest, l.T("validation.manager_not_found"), form, nil)
 			return
 		}
 
-		log.Print(err)
-		render(http.StatusInternalServerError, "failed to create user", UsersForm{
-			Name:  name,
-			Email: email,
-			Age:   ageStr,
-		})
+		api.logger.ErrorContext(r.Context(), "failed to create user", "error", err)
+		render(http.StatusInternalServerError, l.T("error.create_user"), form, nil)
+		return
+	}
+
+	flash := Flash{Kind: "success", Message: l.T("flash.user_created", u.Name)}
+	if partial {
+		render(http.StatusOK, "", newUserForm(defs), &flash)
 		return
 	}
 
-	http.Redirect(w, r, "/users?page=1&limit=10", http.StatusSeeOther)
+	api.setFlash(w, r, flash)
+	http.Redirect(w, r, fmt.Sprintf("/users?page=1&limit=%d", limit), http.StatusSeeOther)
 }
 
+// EditUser saves the fields posted for a user. Fields missing from the
+// form keep their value, so the inline row editor, which only has name,
+// email and date of birth, leaves the rest of the profile alone.
 func (api *Api) EditUser(w http.ResponseWriter, r *http.Request) {
-	idStr := mux.Vars(r)["id"]
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		api.renderTemplate(w, "edit.html", EditPageData{
-			Error: "invalid id",
-		})
+	l := i18n.FromContext(r.Context())
+	partial := isPartial(w, r)
+	existing, status, msg := api.userFromPath(r)
+	if existing == nil {
+		if partial {
+			http.Error(w, msg, status)
+			return
+		}
+		w.WriteHeader(status)
+		api.renderTemplate(w, r, "edit.html", EditPageData{Error: msg})
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+	if !parseForm(w, r) {
 		return
 	}
 
-	name := r.FormValue("name")
-	email := r.FormValue("email")
-	ageStr := r.FormValue("age")
+	id := existing.ID
+	form := userForm(api.attributeDefs(), existing)
+	form.update(r.PostForm)
 
-	u, msg := validateUserInput(name, email, ageStr)
-	if msg != "" {
-		w.WriteHeader(http.StatusBadRequest)
-		api.renderTemplate(w, "edit.html", EditPageData{
-			User:  &database.User{ID: id, Name: name, Email: email},
+	// Inline edits get the row back, or the row's form with the error.
+	render := func(status int, msg string) {
+		w.WriteHeader(status)
+		if partial {
+			api.renderTemplate(w, r, "user-row-edit", UserRowData{
+				User:  existing,
+				Form:  form,
+				Error: msg,
+			})
+			return
+		}
+		api.renderTemplate(w, r, "edit.html", EditPageData{
+			User:  existing,
+			Form:  form,
 			Error: msg,
 		})
+	}
+
+	// Forms without the attributes, such as the inline row editor, keep
+	// the stored ones as they are and don't validate them: a required or
+	// retyped attribute couldn't be fixed there.
+	input := form
+	keepAttributes := !r.PostForm.Has(attributesMarker)
+	if keepAttributes {
+		input.Attributes = nil
+	}
+	u, msg := validateUserInput(l, input)
+	if msg != "" {
+		render(http.StatusBadRequest, msg)
 		return
 	}
 	u.ID = id
+	if keepAttributes {
+		u.Attributes = existing.Attributes
+	}
+	// The birth date stays approximate until it is changed.
+	u.BirthDateApproximate = existing.BirthDateApproximate && u.BirthDate.Equal(existing.BirthDate)
+	// Like other fields, the manager is kept when the form doesn't have it.
+	u.ManagerID = existing.ManagerID
+	if r.PostForm.Has("manager") {
+		if u.ManagerID, msg = api.resolveManager(r.Context(), l, form.Manager); msg != "" {
+			render(http.StatusBadRequest, msg)
+			return
+		}
+	}
 
 	if err := api.db.UpdateUser(r.Context(), u); err != nil {
-		var mysqlErr *mysql.MySQLError
-		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
-			w.WriteHeader(http.StatusBadRequest)
-			api.renderTemplate(w, "edit.html", EditPageData{
-				User:  u,
-				Error: "email already exists",
-			})
+		if database.IsDuplicate(err) {
+			render(http.StatusBadRequest, l.T("error.email_exists"))
+			return
+		}
+		if errors.Is(err, database.ErrManagerNotFound) || errors.Is(err, database.ErrManagerCycle) {
+			render(http.StatusBadRequest, l.T(messageKey(validationMessages, err, "validation.invalid_input")))
 			return
 		}
 
 		if errors.Is(err, database.ErrUserNotFound) {
-			w.WriteHeader(http.StatusNotFound)
-			api.renderTemplate(w, "edit.html", EditPageData{
-				User:  u,
-				Error: "user not found",
-			})
+			render(http.StatusNotFound, l.T("error.user_not_found"))
 			return
 		}
 
-		log.Print(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		api.renderTemplate(w, "edit.html", EditPageData{
-			User:  u,
-			Error: "failed to update user",
-		})
+		api.logger.ErrorContext(r.Context(), "failed to update user", "id", id, "error", err)
+		render(http.StatusInternalServerError, l.T("error.update_user"))
 		return
 	}
 
-	http.Redirect(w, r, "/users", http.StatusSeeOther)
+	if partial {
+		api.renderTemplate(w, r, "user-row", UserRowData{User: u})
+		return
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.user_updated", u.Name)})
+	http.Redirect(w, r, fmt.Sprintf("/users/%d", id), http.StatusSeeOther)
 }
 
 func (api *Api) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
 	idStr := mux.Vars(r)["id"]
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
@@ -300,24 +635,126 @@ func (api *Api) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Look the user up only for the flash message and the avatar files.
+	label, avatarVersion := fmt.Sprintf("#%d", id), ""
+	if u, err := api.db.GetUserByID(r.Context(), id); err == nil {
+		label, avatarVersion = u.Name, u.Avatar
+	}
+
 	err = api.db.DeleteUser(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, database.ErrUserNotFound) {
-			http.Error(w, "user not found", http.StatusNotFound)
+			http.Error(w, l.T("error.user_not_found"), http.StatusNotFound)
 			return
 		}
 
-		log.Printf("failed to delete user %d: %v", id, err)
+		api.logger.ErrorContext(r.Context(), "failed to delete user", "id", id, "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	if avatarVersion != "" {
+		api.deleteAvatar(r, id, avatarVersion)
+	}
 
+	// The script removes the row itself.
+	if isPartial(w, r) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T("flash.user_deleted", label)})
 	http.Redirect(w, r, "/users", http.StatusSeeOther)
 }
 
-func (api *Api) renderTemplate(w http.ResponseWriter, name string, data any) {
-	if err := api.templates.ExecuteTemplate(w, name, data); err != nil {
-		log.Printf("template execution failed (%s): %v", name, err)
+// TagUsers adds the posted tags to the selected users, or removes them
+// when action is "remove", and returns to the list the form was posted
+// from.
+func (api *Api) TagUsers(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	if !parseForm(w, r) {
+		return
+	}
+
+	// Only go back to the users list, never to another site.
+	back := r.PostForm.Get("return")
+	if back != "/users" && !strings.HasPrefix(back, "/users?") {
+		back = "/users"
+	}
+	fail := func(key string, args ...any) {
+		api.setFlash(w, r, Flash{Kind: "error", Message: l.T(key, args...)})
+		http.Redirect(w, r, back, http.StatusSeeOther)
+	}
+
+	var ids []int64
+	for _, s := range r.PostForm["id"] {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			fail("error.invalid_id")
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		fail("error.no_users_selected")
+		return
+	}
+
+	tags, err := database.ParseTags(r.PostForm.Get("tags"))
+	if err != nil {
+		fail("validation.invalid_tag")
+		return
+	}
+	if len(tags) == 0 {
+		fail("error.no_tags")
+		return
+	}
+
+	add, remove := tags, []string(nil)
+	if r.PostForm.Get("action") == "remove" {
+		add, remove = nil, tags
+	}
+	if err := api.db.TagUsers(r.Context(), ids, add, remove); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			fail("error.user_not_found")
+			return
+		}
+
+		api.logger.ErrorContext(r.Context(), "failed to tag users", "ids", ids, "error", err)
+		fail("error.tag_users")
+		return
+	}
+
+	key := "flash.users_tagged"
+	if remove != nil {
+		key = "flash.users_untagged"
+	}
+	api.setFlash(w, r, Flash{Kind: "success", Message: l.T(key, database.FormatTags(tags), len(ids))})
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func parseForm(w http.ResponseWriter, r *http.Request) bool {
+	if err := r.ParseForm(); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (api *Api) renderTemplate(w http.ResponseWriter, r *http.Request, name string, data any) {
+	_, span := tracer.Start(r.Context(), "render "+name)
+	defer span.End()
+
+	if ld, ok := data.(localized); ok {
+		data = ld.withLocalizer(i18n.FromContext(r.Context()))
+	}
+
+	if err := api.tpl().ExecuteTemplate(w, name, data); err != nil {
+		api.logger.ErrorContext(r.Context(), "template execution failed", "template", name, "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
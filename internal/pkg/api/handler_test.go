@@ -3,13 +3,19 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"goapp/internal/pkg/attributes"
 	"goapp/internal/pkg/database"
 
 	mysql "github.com/go-sql-driver/mysql"
@@ -17,16 +23,23 @@ import (
 )
 
 type fakeUserRepo struct {
-	getUsersFn    func(ctx context.Context, limit, offset int) ([]database.User, error)
+	getUsersFn    func(ctx context.Context, q database.UserQuery) ([]database.User, error)
 	getUserByIDFn func(ctx context.Context, id int64) (*database.User, error)
+	getByEmailFn  func(ctx context.Context, email string) (*database.User, error)
 	createUserFn  func(ctx context.Context, u *database.User) error
 	updateUserFn  func(ctx context.Context, u *database.User) error
 	deleteUserFn  func(ctx context.Context, id int64) error
+	getAuditFn    func(ctx context.Context, userID int64) ([]database.AuditEntry, error)
+	getTagsFn     func(ctx context.Context) ([]database.Tag, error)
+	tagUsersFn    func(ctx context.Context, ids []int64, add, remove []string) error
+	getReportsFn  func(ctx context.Context, id int64, depth int) ([]database.Report, error)
+	getChainFn    func(ctx context.Context, id int64) ([]database.User, error)
+	setAvatarFn   func(ctx context.Context, id int64, version string) (string, error)
 }
 
-func (f *fakeUserRepo) GetUsers(ctx context.Context, limit, offset int) ([]database.User, error) {
+func (f *fakeUserRepo) GetUsers(ctx context.Context, q database.UserQuery) ([]database.User, error) {
 	if f.getUsersFn != nil {
-		return f.getUsersFn(ctx, limit, offset)
+		return f.getUsersFn(ctx, q)
 	}
 	return []database.User{}, nil
 }
@@ -38,6 +51,13 @@ func (f *fakeUserRepo) GetUserByID(ctx context.Context, id int64) (*database.Use
 	return nil, database.ErrUserNotFound
 }
 
+func (f *fakeUserRepo) GetUserByEmail(ctx context.Context, email string) (*database.User, error) {
+	if f.getByEmailFn != nil {
+		return f.getByEmailFn(ctx, email)
+	}
+	return nil, database.ErrUserNotFound
+}
+
 func (f *fakeUserRepo) CreateUser(ctx context.Context, u *database.User) error {
 	if f.createUserFn != nil {
 		return f.createUserFn(ctx, u)
@@ -59,16 +79,72 @@ func (f *fakeUserRepo) DeleteUser(ctx context.Context, id int64) error {
 	return nil
 }
 
+func (f *fakeUserRepo) GetUserAudit(ctx context.Context, userID int64) ([]database.AuditEntry, error) {
+	if f.getAuditFn != nil {
+		return f.getAuditFn(ctx, userID)
+	}
+	return []database.AuditEntry{}, nil
+}
+
+func (f *fakeUserRepo) GetTags(ctx context.Context) ([]database.Tag, error) {
+	if f.getTagsFn != nil {
+		return f.getTagsFn(ctx)
+	}
+	return nil, nil
+}
+
+func (f *fakeUserRepo) TagUsers(ctx context.Context, ids []int64, add, remove []string) error {
+	if f.tagUsersFn != nil {
+		return f.tagUsersFn(ctx, ids, add, remove)
+	}
+	return nil
+}
+
+func (f *fakeUserRepo) GetReports(ctx context.Context, id int64, depth int) ([]database.Report, error) {
+	if f.getReportsFn != nil {
+		return f.getReportsFn(ctx, id, depth)
+	}
+	return []database.Report{}, nil
+}
+
+func (f *fakeUserRepo) GetManagementChain(ctx context.Context, id int64) ([]database.User, error) {
+	if f.getChainFn != nil {
+		return f.getChainFn(ctx, id)
+	}
+	return []database.User{}, nil
+}
+
+func (f *fakeUserRepo) SetUserAvatar(ctx context.Context, id int64, version string) (string, error) {
+	if f.setAvatarFn != nil {
+		return f.setAvatarFn(ctx, id, version)
+	}
+	return "", nil
+}
+
 func newTestAPI(repo UserRepository) *Api {
 	tpl := template.Must(template.New("root").Parse(`
 		{{define "users.html"}}ERROR={{.Error}}{{end}}
 		{{define "edit.html"}}ERROR={{.Error}}{{end}}
+		{{define "users-content"}}CONTENT ERROR={{.Error}}{{end}}
+		{{define "user-row"}}ROW={{.User.ID}} {{.User.Name}}{{end}}
+		{{define "user-row-edit"}}EDIT={{.User.ID}} {{.Form.Name}} ERROR={{.Error}}{{end}}
+		{{define "user-row-delete"}}CONFIRM={{.User.ID}}{{end}}
+		{{define "user.html"}}USER={{with .User}}{{.Name}}{{end}} HISTORY={{len .History}} CREATED={{with .User}}{{.CreatedAt.Year}}{{end}} ERROR={{.Error}}{{end}}
+		{{define "delete.html"}}DELETE={{with .User}}{{.Name}}{{end}} ERROR={{.Error}}{{end}}
+		{{define "groups.html"}}GROUPS={{len .Groups}} NAME={{.Form.Name}} ERROR={{.Error}}{{end}}
+		{{define "group.html"}}GROUP={{with .Group}}{{.Name}}{{end}} MEMBERS={{len .Members}} EMAIL={{.MemberEmail}} ERROR={{.Error}}{{end}}
+		{{define "group-edit.html"}}EDIT={{with .Group}}{{.Name}}{{end}} NAME={{.Form.Name}} ERROR={{.Error}}{{end}}
+		{{define "org.html"}}ROOTS={{len .Roots}} UNASSIGNED={{.Unassigned}} ERROR={{.Error}}{{end}}
+		{{define "group-delete.html"}}DELETE={{with .Group}}{{.Name}}{{end}} ERROR={{.Error}}{{end}}
 	`))
 
 	return &Api{
 		router:    mux.NewRouter(),
 		db:        repo,
+		groups:    &fakeGroupRepo{},
 		templates: tpl,
+		logger:    slog.New(slog.DiscardHandler),
+		errs:      make(chan error, 2),
 	}
 }
 
@@ -90,7 +166,7 @@ func TestHealth(t *testing.T) {
 
 func TestCreateUser_InvalidEmail(t *testing.T) {
 	api := newTestAPI(&fakeUserRepo{
-		getUsersFn: func(ctx context.Context, limit, offset int) ([]database.User, error) {
+		getUsersFn: func(ctx context.Context, q database.UserQuery) ([]database.User, error) {
 			return []database.User{}, nil
 		},
 	})
@@ -98,7 +174,7 @@ func TestCreateUser_InvalidEmail(t *testing.T) {
 	form := url.Values{}
 	form.Set("name", "Mahir")
 	form.Set("email", "not-an-email")
-	form.Set("age", "24")
+	form.Set("birth_date", "2000-01-02")
 
 	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -116,7 +192,7 @@ func TestCreateUser_InvalidEmail(t *testing.T) {
 
 func TestCreateUser_DuplicateEmail(t *testing.T) {
 	api := newTestAPI(&fakeUserRepo{
-		getUsersFn: func(ctx context.Context, limit, offset int) ([]database.User, error) {
+		getUsersFn: func(ctx context.Context, q database.UserQuery) ([]database.User, error) {
 			return []database.User{}, nil
 		},
 		createUserFn: func(ctx context.Context, u *database.User) error {
@@ -127,7 +203,7 @@ func TestCreateUser_DuplicateEmail(t *testing.T) {
 	form := url.Values{}
 	form.Set("name", "Mahir")
 	form.Set("email", "mahir@test.com")
-	form.Set("age", "24")
+	form.Set("birth_date", "2000-01-02")
 
 	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -145,7 +221,7 @@ func TestCreateUser_DuplicateEmail(t *testing.T) {
 
 func TestGetUsers_DBError(t *testing.T) {
 	api := newTestAPI(&fakeUserRepo{
-		getUsersFn: func(ctx context.Context, limit, offset int) ([]database.User, error) {
+		getUsersFn: func(ctx context.Context, q database.UserQuery) ([]database.User, error) {
 			return nil, errors.New("db down")
 		},
 	})
@@ -183,3 +259,436 @@ func TestDeleteUser_NotFound(t *testing.T) {
 		t.Fatalf("expected user not found, got %q", w.Body.String())
 	}
 }
+
+func TestCreateUser_TranslatesValidation(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+
+	form := url.Values{}
+	form.Set("name", "Mahir")
+	form.Set("email", "mahir@test.com")
+	form.Set("birth_date", "02.01.2000")
+
+	req := httptest.NewRequest(http.MethodPost, "/users?lang=bs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	localeMiddleware(http.HandlerFunc(api.CreateUser)).ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "neispravan datum rođenja") {
+		t.Fatalf("expected Bosnian validation message, got %q", w.Body.String())
+	}
+}
+
+func partialRequest(method, target string, form url.Values) *http.Request {
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	req.Header.Set("HX-Request", "true")
+	return req
+}
+
+func TestGetUsers_PartialSearch(t *testing.T) {
+	var got database.UserQuery
+	api := newTestAPI(&fakeUserRepo{
+		getUsersFn: func(ctx context.Context, q database.UserQuery) ([]database.User, error) {
+			got = q
+			return []database.User{}, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	api.GetUsers(w, partialRequest(http.MethodGet, "/users?q=mahir&page=2", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if !strings.HasPrefix(w.Body.String(), "CONTENT") {
+		t.Fatalf("expected users-content fragment, got %q", w.Body.String())
+	}
+	if got.Search != "mahir" || got.Offset != got.Limit {
+		t.Fatalf("expected search on page 2, got %+v", got)
+	}
+	if w.Header().Get("Vary") != "HX-Request" {
+		t.Fatalf("expected Vary: HX-Request, got %q", w.Header().Get("Vary"))
+	}
+}
+
+func TestEditUser_Partial(t *testing.T) {
+	var saved *database.User
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Old", Email: "old@test.com", BirthDate: time.Date(2004, 1, 2, 0, 0, 0, 0, time.UTC), Department: "IT", Phone: "+387 61 123 456"}, nil
+		},
+		updateUserFn: func(ctx context.Context, u *database.User) error {
+			saved = u
+			return nil
+		},
+	})
+
+	form := url.Values{}
+	form.Set("name", "Mahir")
+	form.Set("email", "mahir@test.com")
+	form.Set("birth_date", "2000-01-02")
+
+	req := mux.SetURLVars(partialRequest(http.MethodPost, "/users/7", form), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.EditUser(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "ROW=7 Mahir" {
+		t.Fatalf("expected row fragment, got %d %q", w.Code, w.Body.String())
+	}
+	if saved.Department != "IT" || saved.Phone != "+387 61 123 456" {
+		t.Fatalf("expected fields missing from the row to be kept, got %+v", saved)
+	}
+
+	form.Set("email", "not-an-email")
+	req = mux.SetURLVars(partialRequest(http.MethodPost, "/users/7", form), map[string]string{"id": "7"})
+	w = httptest.NewRecorder()
+	api.EditUser(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if w.Body.String() != "EDIT=7 Mahir ERROR=invalid email format" {
+		t.Fatalf("expected edit row with error, got %q", w.Body.String())
+	}
+}
+
+func TestDeleteUser_PartialReturnsEmptyBody(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+
+	req := mux.SetURLVars(partialRequest(http.MethodPost, "/users/7/delete", nil), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.DeleteUser(w, req)
+
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("expected empty 200, got %d %q", w.Code, w.Body.String())
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Fatalf("expected no flash cookie, got %v", w.Result().Cookies())
+	}
+}
+
+func TestGetUser_ShowsHistory(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir", CreatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}, nil
+		},
+		getAuditFn: func(ctx context.Context, userID int64) ([]database.AuditEntry, error) {
+			return []database.AuditEntry{
+				{Action: database.AuditCreated, At: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Action: database.AuditUpdated, At: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			}, nil
+		},
+	})
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/users/7", nil), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.GetUser(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Body.String(); got != "USER=Mahir HISTORY=2 CREATED=2023 ERROR=" {
+		t.Fatalf("unexpected body %q", got)
+	}
+}
+
+func TestGetUser_NotFound(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/users/7", nil), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.GetUser(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "ERROR=user not found") {
+		t.Fatalf("expected user not found, got %q", w.Body.String())
+	}
+}
+
+func TestConfirmDeleteUser(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir"}, nil
+		},
+		deleteUserFn: func(ctx context.Context, id int64) error {
+			t.Fatalf("expected no delete on GET")
+			return nil
+		},
+	})
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/users/7/delete", nil), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.ConfirmDeleteUser(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "DELETE=Mahir ERROR=" {
+		t.Fatalf("expected confirmation page, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = mux.SetURLVars(partialRequest(http.MethodGet, "/users/7/delete", nil), map[string]string{"id": "7"})
+	w = httptest.NewRecorder()
+	api.ConfirmDeleteUser(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "CONFIRM=7" {
+		t.Fatalf("expected confirmation row, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestEditUser_RedirectsToDetail(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Old", Email: "old@test.com", BirthDate: time.Date(2004, 1, 2, 0, 0, 0, 0, time.UTC)}, nil
+		},
+	})
+	api.sessionKey = []byte("test-secret")
+
+	form := url.Values{}
+	form.Set("name", "Mahir")
+	form.Set("email", "mahir@test.com")
+	form.Set("birth_date", "2000-01-02")
+
+	req := httptest.NewRequest(http.MethodPost, "/users/7/edit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = mux.SetURLVars(req, map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.EditUser(w, req)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/users/7" {
+		t.Fatalf("expected redirect to /users/7, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestUsersPageData_SortURL(t *testing.T) {
+	d := UsersPageData{Search: "ana", Sort: "name", Limit: 10, Page: 3}
+
+	if got := d.SortURL("name"); got != "/users?limit=10&page=1&q=ana&sort=-name" {
+		t.Fatalf("expected descending sort, got %q", got)
+	}
+	if got := d.SortURL("age"); got != "/users?limit=10&page=1&q=ana&sort=age" {
+		t.Fatalf("expected sort by age, got %q", got)
+	}
+	if got := d.PageURL(4); got != "/users?limit=10&page=4&q=ana&sort=name" {
+		t.Fatalf("expected page 4 with the same sort, got %q", got)
+	}
+}
+
+func TestEditUser_Attributes(t *testing.T) {
+	var saved *database.User
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir", Email: "mahir@test.com", BirthDate: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+				Attributes: map[string]any{"remote": true, "retired": "x"}}, nil
+		},
+		updateUserFn: func(ctx context.Context, u *database.User) error {
+			saved = u
+			return nil
+		},
+	})
+	api.sessionKey = []byte("test-secret")
+	api.attributes = attributes.Defs{
+		{Name: "cost_center", Label: "Cost center", Type: attributes.String, Required: true},
+		{Name: "remote", Type: attributes.Bool},
+		{Name: "start_date", Type: attributes.Date},
+	}
+
+	edit := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users/7/edit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		api.EditUser(w, mux.SetURLVars(req, map[string]string{"id": "7"}))
+		return w
+	}
+
+	form := url.Values{}
+	form.Set("attributes", "1")
+	form.Set("attr.start_date", "2024-03-01")
+	w := edit(form)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Cost center is required") {
+		t.Fatalf("expected required attribute error, got %d %q", w.Code, w.Body.String())
+	}
+
+	form.Set("attr.cost_center", "R&D")
+	w = edit(form)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d %q", w.Code, w.Body.String())
+	}
+	want := map[string]any{"cost_center": "R&D", "remote": false, "start_date": "2024-03-01"}
+	if !reflect.DeepEqual(saved.Attributes, want) || saved.Name != "Mahir" {
+		t.Fatalf("expected attributes %v, got %+v", want, saved)
+	}
+}
+
+func TestEditUser_KeepsApproximateBirthDateUntilChanged(t *testing.T) {
+	approx := time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)
+	var saved *database.User
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir", Email: "mahir@test.com", BirthDate: approx, BirthDateApproximate: true}, nil
+		},
+		updateUserFn: func(ctx context.Context, u *database.User) error {
+			saved = u
+			return nil
+		},
+	})
+
+	for date, want := range map[string]bool{"1990-01-02": true, "1990-03-04": false} {
+		form := url.Values{}
+		form.Set("name", "Mahir Z")
+		form.Set("birth_date", date)
+		req := mux.SetURLVars(partialRequest(http.MethodPost, "/users/7/edit", form), map[string]string{"id": "7"})
+		api.EditUser(httptest.NewRecorder(), req)
+
+		if saved == nil || saved.BirthDateApproximate != want {
+			t.Fatalf("birth date %s: expected approximate=%v, got %+v", date, want, saved)
+		}
+	}
+}
+
+func TestEditUser_WrappedManagerError(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir", Email: "mahir@test.com", BirthDate: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)}, nil
+		},
+		updateUserFn: func(ctx context.Context, u *database.User) error {
+			return fmt.Errorf("update user %d: %w", u.ID, database.ErrManagerNotFound)
+		},
+	})
+
+	form := url.Values{"name": {"Mahir"}, "email": {"mahir@test.com"}, "birth_date": {"2000-01-02"}}
+	req := mux.SetURLVars(partialRequest(http.MethodPost, "/users/7", form), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.EditUser(w, req)
+
+	if w.Code != http.StatusBadRequest || w.Body.String() != "EDIT=7 Mahir ERROR=no user with that manager email" {
+		t.Fatalf("expected the manager message, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMessageKey(t *testing.T) {
+	if key := messageKey(validationMessages, fmt.Errorf("validate: %w", database.ErrInvalidEmail), "fallback"); key != "validation.invalid_email" {
+		t.Fatalf("expected the key of the wrapped error, got %q", key)
+	}
+	if key := messageKey(validationMessages, errors.New("something else"), "fallback"); key != "fallback" {
+		t.Fatalf("expected the fallback for an unknown error, got %q", key)
+	}
+}
+
+func TestEditUser_InlineKeepsAttributes(t *testing.T) {
+	stored := map[string]any{"remote": "not a bool", "retired": "x"}
+	var saved *database.User
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir", Email: "mahir@test.com", BirthDate: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+				Attributes: stored}, nil
+		},
+		updateUserFn: func(ctx context.Context, u *database.User) error {
+			saved = u
+			return nil
+		},
+	})
+	// Added after the user was saved: the user has no cost center, and
+	// remote was a string before it became a bool.
+	api.attributes = attributes.Defs{
+		{Name: "cost_center", Label: "Cost center", Type: attributes.String, Required: true},
+		{Name: "remote", Type: attributes.Bool},
+	}
+
+	form := url.Values{"name": {"Mahir Z"}, "email": {"mahir@test.com"}, "birth_date": {"2000-01-02"}}
+	req := mux.SetURLVars(partialRequest(http.MethodPost, "/users/7/edit", form), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.EditUser(w, req)
+
+	if w.Code != http.StatusOK || saved == nil {
+		t.Fatalf("expected the row to be saved, got %d %q", w.Code, w.Body.String())
+	}
+	if saved.Name != "Mahir Z" || !reflect.DeepEqual(saved.Attributes, stored) {
+		t.Fatalf("expected the name changed and the attributes kept, got %+v", saved)
+	}
+}
+
+func TestGetUsers_TagFilter(t *testing.T) {
+	var got database.UserQuery
+	api := newTestAPI(&fakeUserRepo{
+		getUsersFn: func(ctx context.Context, q database.UserQuery) ([]database.User, error) {
+			got = q
+			return []database.User{}, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	api.GetUsers(w, httptest.NewRequest(http.MethodGet, "/users?tag=VIP&tag=on-call", nil))
+	if w.Code != http.StatusOK || !slices.Equal(got.Tags, []string{"vip", "on-call"}) {
+		t.Fatalf("expected tags vip and on-call to be filtered, got %d %+v", w.Code, got.Tags)
+	}
+
+	w = httptest.NewRecorder()
+	api.GetUsers(w, httptest.NewRequest(http.MethodGet, "/users?tag=a%20b", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "ERROR=invalid tag") {
+		t.Fatalf("expected invalid tag, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTagUsers(t *testing.T) {
+	var gotIDs []int64
+	var gotAdd, gotRemove []string
+	api := newTestAPI(&fakeUserRepo{
+		tagUsersFn: func(ctx context.Context, ids []int64, add, remove []string) error {
+			gotIDs, gotAdd, gotRemove = ids, add, remove
+			return nil
+		},
+	})
+
+	form := url.Values{"id": {"1", "4"}, "tags": {"VIP, ops"}, "action": {"remove"}, "return": {"/users?page=2"}}
+	req := httptest.NewRequest(http.MethodPost, "/users/tags", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	api.TagUsers(w, req)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/users?page=2" {
+		t.Fatalf("expected redirect to /users?page=2, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if !slices.Equal(gotIDs, []int64{1, 4}) || gotAdd != nil || !slices.Equal(gotRemove, []string{"ops", "vip"}) {
+		t.Fatalf("unexpected call %v %v %v", gotIDs, gotAdd, gotRemove)
+	}
+}
+
+func TestTagUsers_Errors(t *testing.T) {
+	tests := []url.Values{
+		{"tags": {"vip"}},
+		{"id": {"1"}},
+		{"id": {"1"}, "tags": {"a b"}},
+		{"id": {"x"}, "tags": {"vip"}},
+	}
+	for _, form := range tests {
+		called := false
+		api := newTestAPI(&fakeUserRepo{
+			tagUsersFn: func(ctx context.Context, ids []int64, add, remove []string) error {
+				called = true
+				return nil
+			},
+		})
+
+		form.Set("return", "https://example.com/users")
+		req := httptest.NewRequest(http.MethodPost, "/users/tags", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		api.TagUsers(w, req)
+
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/users" {
+			t.Fatalf("%v: expected redirect to /users, got %d %q", form, w.Code, w.Header().Get("Location"))
+		}
+		if called {
+			t.Fatalf("%v: expected tags to be left alone", form)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type componentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components,omitempty"`
+}
+
+// AddReadinessCheck registers a component that must be healthy for /readyz
+// to succeed. Checks run concurrently, each bounded by the configured
+// health timeout.
+func (api *Api) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	api.readiness = append(api.readiness, readinessCheck{name: name, check: check})
+}
+
+func (api *Api) Livez(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+func (api *Api) Readyz(w http.ResponseWriter, r *http.Request) {
+	if api.shuttingDown.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: "shutting_down"})
+		return
+	}
+
+	timeout := api.healthTimeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	resp := healthResponse{Status: "ok", Components: make(map[string]componentStatus, len(api.readiness))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range api.readiness {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			status := componentStatus{Status: "ok"}
+			if err := c.check(ctx); err != nil {
+				status = componentStatus{Status: "error", Error: err.Error()}
+			}
+
+			mu.Lock()
+			resp.Components[c.name] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for name, c := range resp.Components {
+		if c.Status != "ok" {
+			api.logger.WarnContext(r.Context(), "readiness check failed", "component", name, "error", c.Error)
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	writeHealth(w, code, resp)
+}
+
+func writeHealth(w http.ResponseWriter, code int, resp healthResponse) {
+	writeJSON(w, code, resp)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveReadyz(t *testing.T, api *Api) (int, healthResponse) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	api.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var resp healthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected JSON body, got %q", w.Body.String())
+	}
+	return w.Code, resp
+}
+
+func TestReadyz_AllComponentsHealthy(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.AddReadinessCheck("database", func(ctx context.Context) error { return nil })
+
+	code, resp := serveReadyz(t, api)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if resp.Components["database"].Status != "ok" {
+		t.Fatalf("expected database ok, got %+v", resp.Components)
+	}
+}
+
+func TestReadyz_FailingComponent(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.AddReadinessCheck("database", func(ctx context.Context) error { return nil })
+	api.AddReadinessCheck("migrations", func(ctx context.Context) error { return errors.New("2 pending") })
+
+	code, resp := serveReadyz(t, api)
+
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", code)
+	}
+	if resp.Status != "unavailable" {
+		t.Fatalf("expected status unavailable, got %q", resp.Status)
+	}
+	if resp.Components["migrations"].Error != "2 pending" {
+		t.Fatalf("expected migrations error, got %+v", resp.Components)
+	}
+}
+
+func TestReadyz_FailsWhileShuttingDown(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.shuttingDown.Store(true)
+
+	code, resp := serveReadyz(t, api)
+
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", code)
+	}
+	if resp.Status != "shutting_down" {
+		t.Fatalf("expected status shutting_down, got %q", resp.Status)
+	}
+}
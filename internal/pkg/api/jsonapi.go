@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/i18n"
+	"net/http"
+	"strconv"
+)
+
+// userListResponse is the body of GET /api/users.
+type userListResponse struct {
+	Users []database.User `json:"users"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
+}
+
+// reportListResponse is the body of GET /api/users/{id}/reports.
+type reportListResponse struct {
+	Reports []database.Report `json:"reports"`
+}
+
+// managerListResponse is the body of GET /api/users/{id}/managers.
+type managerListResponse struct {
+	Managers []database.User `json:"managers"`
+}
+
+// tagListResponse is the body of GET /api/tags.
+type tagListResponse struct {
+	Tags []database.Tag `json:"tags"`
+}
+
+// groupListResponse is the body of GET /api/groups.
+type groupListResponse struct {
+	Groups []database.Group `json:"groups"`
+}
+
+// groupResponse is the body of GET /api/groups/{id}.
+type groupResponse struct {
+	*database.Group
+	Members []database.Member `json:"members"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ListUsersJSON lists users like the users page, taking the same q, sort,
+// created_after, created_before, born_after, born_before, group, tag (which
+// may be repeated), page and limit parameters.
+func (api *Api) ListUsersJSON(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+
+	q, page, errKey := api.parseUserQuery(r.URL.Query())
+	if errKey != "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: l.T(errKey)})
+		return
+	}
+
+	users, err := api.db.GetUsers(r.Context(), q)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch users", "error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: l.T("error.fetch_users")})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, userListResponse{Users: users, Page: page, Limit: q.Limit})
+}
+
+func (api *Api) GetUserJSON(w http.ResponseWriter, r *http.Request) {
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		writeJSON(w, status, errorResponse{Error: msg})
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ListReportsJSON lists the direct reports of a user, or with
+// transitive=true everyone below the user, each with their depth.
+func (api *Api) ListReportsJSON(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		writeJSON(w, status, errorResponse{Error: msg})
+		return
+	}
+
+	depth := 1
+	if v := r.URL.Query().Get("transitive"); v != "" {
+		transitive, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: l.T("error.invalid_transitive")})
+			return
+		}
+		if transitive {
+			depth = 0
+		}
+	}
+
+	reports, err := api.db.GetReports(r.Context(), user.ID, depth)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch reports", "id", user.ID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: l.T("error.fetch_reports")})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reportListResponse{Reports: reports})
+}
+
+// ListManagersJSON lists the management chain of a user, from the direct
+// manager up.
+func (api *Api) ListManagersJSON(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	user, status, msg := api.userFromPath(r)
+	if user == nil {
+		writeJSON(w, status, errorResponse{Error: msg})
+		return
+	}
+
+	managers, err := api.db.GetManagementChain(r.Context(), user.ID)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch management chain", "id", user.ID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: l.T("error.fetch_managers")})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, managerListResponse{Managers: managers})
+}
+
+// ListTagsJSON lists the tags in use with their number of users.
+func (api *Api) ListTagsJSON(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+
+	tags, err := api.db.GetTags(r.Context())
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch tags", "error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: l.T("error.fetch_tags")})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tagListResponse{Tags: tags})
+}
+
+// ListGroupsJSON lists all groups, or those whose name contains q.
+func (api *Api) ListGroupsJSON(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+
+	groups, err := api.groups.GetGroups(r.Context(), database.GroupQuery{Search: r.URL.Query().Get("q")})
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch groups", "error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: l.T("error.fetch_groups")})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, groupListResponse{Groups: groups})
+}
+
+// GetGroupJSON returns a group with its members.
+func (api *Api) GetGroupJSON(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+	group, status, msg := api.groupFromPath(r)
+	if group == nil {
+		writeJSON(w, status, errorResponse{Error: msg})
+		return
+	}
+
+	members, err := api.groups.GetGroupMembers(r.Context(), group.ID)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch group members", "id", group.ID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: l.T("error.fetch_members")})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, groupResponse{Group: group, Members: members})
+}
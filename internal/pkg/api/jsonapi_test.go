@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goapp/internal/pkg/database"
+
+	"github.com/gorilla/mux"
+)
+
+func TestListUsersJSON(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var got database.UserQuery
+	api := newTestAPI(&fakeUserRepo{
+		getUsersFn: func(ctx context.Context, q database.UserQuery) ([]database.User, error) {
+			got = q
+			return []database.User{{ID: 1, Name: "Ana", CreatedAt: created, UpdatedAt: created}}, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	api.ListUsersJSON(w, httptest.NewRequest(http.MethodGet, "/api/users?sort=-created_at&created_after=2024-01-01", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got.Sort != "-created_at" || !got.CreatedAfter.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected query %+v", got)
+	}
+
+	var resp struct {
+		Users []map[string]any `json:"users"`
+		Page  int              `json:"page"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Page != 1 || len(resp.Users) != 1 || resp.Users[0]["created_at"] != "2024-05-01T10:00:00Z" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestListUsersJSON_InvalidParams(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+
+	for _, target := range []string{"/api/users?sort=password", "/api/users?created_before=yesterday"} {
+		w := httptest.NewRecorder()
+		api.ListUsersJSON(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestGetUserJSON_NotFound(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/users/9", nil), map[string]string{"id": "9"})
+	w := httptest.NewRecorder()
+	api.GetUserJSON(w, req)
+
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected JSON 404, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestListTagsJSON(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{
+		getTagsFn: func(ctx context.Context) ([]database.Tag, error) {
+			return []database.Tag{{Name: "vip", Users: 2}}, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	api.ListTagsJSON(w, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != `{"tags":[{"name":"vip","users":2}]}` {
+		t.Fatalf("unexpected response %s", got)
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/i18n"
+	"goapp/internal/pkg/logging"
+	"goapp/internal/pkg/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	h := securityHeadersMiddleware(config.HeadersConfig{
+		ContentSecurityPolicy: "default-src 'self'",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		HSTSMaxAge:            time.Hour,
+		HSTSIncludeSubdomains: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Fatalf("unexpected CSP %q", got)
+	}
+	if got := w.Header().Get("X-Frame-Options"); got != "DENY" {
+		t.Fatalf("unexpected X-Frame-Options %q", got)
+	}
+	if got := w.Header().Get("Referrer-Policy"); got != "no-referrer" {
+		t.Fatalf("unexpected Referrer-Policy %q", got)
+	}
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Fatalf("expected no HSTS over plain HTTP, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Fatalf("expected X-Forwarded-Proto not to be trusted, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains" {
+		t.Fatalf("unexpected HSTS %q", got)
+	}
+}
+
+func TestMaxBodyMiddleware_RejectsLargeForm(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	h := maxBodyMiddleware(func(*http.Request) int64 { return 16 })(http.HandlerFunc(api.CreateUser))
+
+	form := url.Values{}
+	form.Set("name", strings.Repeat("a", 64))
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", w.Code)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	h := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("X-Request-ID", "from-proxy-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if seen != "from-proxy-1" {
+		t.Fatalf("expected incoming request id, got %q", seen)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "from-proxy-1" {
+		t.Fatalf("expected request id echoed, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if seen == "" || seen == "bad id\nwith newline" {
+		t.Fatalf("expected generated request id, got %q", seen)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != seen {
+		t.Fatalf("expected generated id %q echoed, got %q", seen, got)
+	}
+}
+
+func TestTracingMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceID string
+	h := tracingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = trace.SpanContextFromContext(r.Context()).TraceID().String()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected incoming trace id, got %q", traceID)
+	}
+}
+
+func TestLocaleMiddleware(t *testing.T) {
+	var got string
+	h := localeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = i18n.FromContext(r.Context()).Lang()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept-Language", "bs-BA,en;q=0.5")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got != "bs" || w.Header().Get("Content-Language") != "bs" {
+		t.Fatalf("expected bs from Accept-Language, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users?lang=en", nil)
+	req.Header.Set("Accept-Language", "bs")
+	req.AddCookie(&http.Cookie{Name: localeCookie, Value: "bs"})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got != "en" {
+		t.Fatalf("expected query to win, got %q", got)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != localeCookie || cookies[0].Value != "en" {
+		t.Fatalf("expected lang cookie to be set, got %v", cookies)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept-Language", "en")
+	req.AddCookie(&http.Cookie{Name: localeCookie, Value: "bs"})
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "bs" {
+		t.Fatalf("expected cookie to beat Accept-Language, got %q", got)
+	}
+}
+
+func TestMiddleware_UnmatchedRequests(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{})
+	api.registerMiddleware(config.HeadersConfig{FrameOptions: "DENY"})
+	api.registerHandlers()
+	api.metrics = metrics.New()
+
+	for path, status := range map[string]int{"/nope": http.StatusNotFound, "/users/7/edit": http.StatusMethodNotAllowed} {
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, path, nil))
+
+		if w.Code != status {
+			t.Fatalf("%s: expected %d, got %d", path, status, w.Code)
+		}
+		if w.Header().Get(requestIDHeader) == "" || w.Header().Get("X-Frame-Options") != "DENY" {
+			t.Fatalf("%s: expected the middleware headers, got %v", path, w.Header())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	api.metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`goapp_http_requests_total{method="DELETE",route="unmatched",status="404"} 1`,
+		`goapp_http_requests_total{method="DELETE",route="unmatched",status="405"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("expected metrics output to contain %q", want)
+		}
+	}
+}
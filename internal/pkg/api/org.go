@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/i18n"
+	"net/http"
+	"strings"
+)
+
+// OrgNode is a user in the org chart with the users reporting to them.
+type OrgNode struct {
+	User    database.User
+	Reports []*OrgNode
+}
+
+// OrgPageData is the data of the org chart page.
+type OrgPageData struct {
+	// Roots are the users without a manager who have reports.
+	Roots []*OrgNode
+	// Unassigned counts the users with neither a manager nor reports.
+	Unassigned int
+	Error      string
+	Flash      *Flash
+	L          *i18n.Localizer
+}
+
+func (d OrgPageData) withLocalizer(l *i18n.Localizer) any {
+	d.L = l
+	return d
+}
+
+// buildOrgTree arranges reports, in the order GetReports returns them,
+// into trees under the user with id root, or under no one when root is 0.
+func buildOrgTree(root int64, reports []database.Report) []*OrgNode {
+	children := make(map[int64][]*OrgNode)
+	for _, r := range reports {
+		var manager int64
+		if r.User.ManagerID != nil {
+			manager = *r.User.ManagerID
+		}
+		children[manager] = append(children[manager], &OrgNode{User: r.User})
+	}
+	for _, nodes := range children {
+		for _, n := range nodes {
+			n.Reports = children[n.User.ID]
+		}
+	}
+	return children[root]
+}
+
+// GetOrg shows the org chart: every user without a manager, with the
+// users below them.
+func (api *Api) GetOrg(w http.ResponseWriter, r *http.Request) {
+	l := i18n.FromContext(r.Context())
+
+	reports, err := api.db.GetReports(r.Context(), 0, 0)
+	if err != nil {
+		api.logger.ErrorContext(r.Context(), "failed to fetch org chart", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		api.renderTemplate(w, r, "org.html", OrgPageData{Error: l.T("error.fetch_org")})
+		return
+	}
+
+	data := OrgPageData{Flash: api.popFlash(w, r)}
+	for _, n := range buildOrgTree(0, reports) {
+		if len(n.Reports) == 0 {
+			data.Unassigned++
+			continue
+		}
+		data.Roots = append(data.Roots, n)
+	}
+	api.renderTemplate(w, r, "org.html", data)
+}
+
+// managerEmail returns the email of u's manager, which is how forms name
+// it, or "" when u has none.
+func (api *Api) managerEmail(ctx context.Context, u *database.User) (string, error) {
+	if u.ManagerID == nil {
+		return "", nil
+	}
+	m, err := api.db.GetUserByID(ctx, *u.ManagerID)
+	if err != nil {
+		return "", err
+	}
+	return m.Email, nil
+}
+
+// resolveManager looks up the manager a form names by email. An empty
+// email means no manager. When that fails it returns the message to show.
+func (api *Api) resolveManager(ctx context.Context, l *i18n.Localizer, email string) (*int64, string) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, ""
+	}
+
+	m, err := api.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			return nil, l.T("validation.manager_not_found")
+		}
+		api.logger.ErrorContext(ctx, "failed to fetch manager", "email", email, "error", err)
+		return nil, l.T("error.fetch_user")
+	}
+	return &m.ID, ""
+}
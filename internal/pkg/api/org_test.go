@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"goapp/internal/pkg/database"
+
+	"github.com/gorilla/mux"
+)
+
+func managedBy(id, manager int64, depth int) database.Report {
+	u := database.User{ID: id}
+	if manager != 0 {
+		u.ManagerID = &manager
+	}
+	return database.Report{User: u, Depth: depth}
+}
+
+func TestBuildOrgTree(t *testing.T) {
+	reports := []database.Report{managedBy(1, 0, 1), managedBy(2, 0, 1), managedBy(3, 1, 2), managedBy(4, 1, 2), managedBy(5, 3, 3)}
+
+	roots := buildOrgTree(0, reports)
+	if len(roots) != 2 || roots[0].User.ID != 1 || roots[1].User.ID != 2 {
+		t.Fatalf("unexpected roots %+v", roots)
+	}
+	below := roots[0].Reports
+	if len(below) != 2 || below[0].User.ID != 3 || below[1].User.ID != 4 || len(below[0].Reports) != 1 || below[0].Reports[0].User.ID != 5 {
+		t.Fatalf("unexpected reports of 1 %+v", below)
+	}
+
+	if sub := buildOrgTree(3, reports[4:]); len(sub) != 1 || sub[0].User.ID != 5 {
+		t.Fatalf("unexpected subtree %+v", sub)
+	}
+}
+
+func TestGetOrg(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{
+		getReportsFn: func(ctx context.Context, id int64, depth int) ([]database.Report, error) {
+			if id != 0 || depth != 0 {
+				t.Fatalf("expected the whole organization, got %d %d", id, depth)
+			}
+			return []database.Report{managedBy(1, 0, 1), managedBy(2, 0, 1), managedBy(3, 1, 2)}, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	api.GetOrg(w, httptest.NewRequest(http.MethodGet, "/org", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "ROOTS=1 UNASSIGNED=1 ERROR=" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestEditUser_Manager(t *testing.T) {
+	manager := int64(3)
+	tests := []struct {
+		form   url.Values
+		status int
+		want   *int64
+	}{
+		// The manager is looked up by email, and cleared by an empty one.
+		{url.Values{"manager": {"boss@test.com"}}, http.StatusSeeOther, &manager},
+		{url.Values{"manager": {""}}, http.StatusSeeOther, nil},
+		{url.Values{"manager": {"nobody@test.com"}}, http.StatusBadRequest, nil},
+		// The inline row editor doesn't post it, which keeps the current one.
+		{url.Values{}, http.StatusSeeOther, &manager},
+	}
+	for _, tt := range tests {
+		var got *database.User
+		api := newTestAPI(&fakeUserRepo{
+			getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+				return &database.User{ID: id, Name: "Mahir", Email: "mahir@test.com", BirthDate: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC), ManagerID: &manager}, nil
+			},
+			getByEmailFn: func(ctx context.Context, email string) (*database.User, error) {
+				if email != "boss@test.com" {
+					return nil, database.ErrUserNotFound
+				}
+				return &database.User{ID: 3, Email: email}, nil
+			},
+			updateUserFn: func(ctx context.Context, u *database.User) error {
+				got = u
+				return nil
+			},
+		})
+		api.sessionKey = []byte("test-secret")
+
+		tt.form.Set("name", "Mahir")
+		tt.form.Set("email", "mahir@test.com")
+		tt.form.Set("birth_date", "2000-01-02")
+		req := httptest.NewRequest(http.MethodPost, "/users/7/edit", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = mux.SetURLVars(req, map[string]string{"id": "7"})
+		w := httptest.NewRecorder()
+		api.EditUser(w, req)
+
+		if w.Code != tt.status {
+			t.Fatalf("%v: expected %d, got %d %q", tt.form, tt.status, w.Code, w.Body.String())
+		}
+		if tt.status != http.StatusSeeOther {
+			if got != nil {
+				t.Fatalf("%v: expected no update", tt.form)
+			}
+			continue
+		}
+		if (got.ManagerID == nil) != (tt.want == nil) || (tt.want != nil && *got.ManagerID != *tt.want) {
+			t.Fatalf("%v: expected manager %v, got %v", tt.form, tt.want, got.ManagerID)
+		}
+	}
+}
+
+func TestEditUser_ManagerCycle(t *testing.T) {
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id, Name: "Mahir", Email: "mahir@test.com", BirthDate: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)}, nil
+		},
+		getByEmailFn: func(ctx context.Context, email string) (*database.User, error) {
+			return &database.User{ID: 9, Email: email}, nil
+		},
+		updateUserFn: func(ctx context.Context, u *database.User) error {
+			return database.ErrManagerCycle
+		},
+	})
+
+	form := url.Values{"name": {"Mahir"}, "email": {"mahir@test.com"}, "birth_date": {"2000-01-02"}, "manager": {"report@test.com"}}
+	req := httptest.NewRequest(http.MethodPost, "/users/7/edit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = mux.SetURLVars(req, map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.EditUser(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "report to themselves or to one of their reports") {
+		t.Fatalf("expected cycle error, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestListReportsJSON(t *testing.T) {
+	var gotDepth int
+	api := newTestAPI(&fakeUserRepo{
+		getUserByIDFn: func(ctx context.Context, id int64) (*database.User, error) {
+			return &database.User{ID: id}, nil
+		},
+		getReportsFn: func(ctx context.Context, id int64, depth int) ([]database.Report, error) {
+			gotDepth = depth
+			return []database.Report{managedBy(8, id, 1)}, nil
+		},
+	})
+
+	for query, want := range map[string]int{"": 1, "?transitive=true": 0} {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/users/7/reports"+query, nil), map[string]string{"id": "7"})
+		w := httptest.NewRecorder()
+		api.ListReportsJSON(w, req)
+
+		if w.Code != http.StatusOK || gotDepth != want {
+			t.Fatalf("%q: expected depth %d, got %d %d", query, want, w.Code, gotDepth)
+		}
+		var resp struct {
+			Reports []struct {
+				User struct {
+					ID        int64 `json:"id"`
+					ManagerID int64 `json:"manager_id"`
+				} `json:"user"`
+				Depth int `json:"depth"`
+			} `json:"reports"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("expected JSON body, got %v", err)
+		}
+		if len(resp.Reports) != 1 || resp.Reports[0].User.ID != 8 || resp.Reports[0].User.ManagerID != 7 || resp.Reports[0].Depth != 1 {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/users/7/reports?transitive=maybe", nil), map[string]string{"id": "7"})
+	w := httptest.NewRecorder()
+	api.ListReportsJSON(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
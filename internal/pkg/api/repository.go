@@ -6,9 +6,28 @@ import (
 )
 
 type UserRepository interface {
-	GetUsers(ctx context.Context, limit, offset int) ([]database.User, error)
+	GetUsers(ctx context.Context, q database.UserQuery) ([]database.User, error)
 	GetUserByID(ctx context.Context, id int64) (*database.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*database.User, error)
 	CreateUser(ctx context.Context, u *database.User) error
 	UpdateUser(ctx context.Context, u *database.User) error
 	DeleteUser(ctx context.Context, id int64) error
+	GetUserAudit(ctx context.Context, userID int64) ([]database.AuditEntry, error)
+	GetTags(ctx context.Context) ([]database.Tag, error)
+	TagUsers(ctx context.Context, ids []int64, add, remove []string) error
+	GetReports(ctx context.Context, id int64, depth int) ([]database.Report, error)
+	GetManagementChain(ctx context.Context, id int64) ([]database.User, error)
+	SetUserAvatar(ctx context.Context, id int64, version string) (string, error)
+}
+
+type GroupRepository interface {
+	GetGroups(ctx context.Context, q database.GroupQuery) ([]database.Group, error)
+	GetGroupByID(ctx context.Context, id int64) (*database.Group, error)
+	CreateGroup(ctx context.Context, g *database.Group) error
+	UpdateGroup(ctx context.Context, g *database.Group) error
+	DeleteGroup(ctx context.Context, id int64) error
+	GetGroupMembers(ctx context.Context, groupID int64) ([]database.Member, error)
+	GetUserGroups(ctx context.Context, userID int64) ([]database.Membership, error)
+	SetGroupMember(ctx context.Context, groupID, userID int64, role string) error
+	RemoveGroupMember(ctx context.Context, groupID, userID int64) error
 }
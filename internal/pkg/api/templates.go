@@ -0,0 +1,247 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/i18n"
+	"goapp/templates"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Renderer executes a named template; *template.Template satisfies it.
+type Renderer interface {
+	ExecuteTemplate(w io.Writer, name string, data any) error
+}
+
+// overrides splits templates.path into the directory holding custom
+// templates and static/ and the pattern selecting the templates. The path
+// may be a directory or, as in older configs, a glob like templates/*.html.
+func overrides(path string) (dir, pattern string) {
+	if path == "" {
+		return "", ""
+	}
+	if strings.ContainsAny(path, "*?[") {
+		return filepath.Dir(path), filepath.Base(path)
+	}
+	return path, "*.html"
+}
+
+// pages holds a template set per page. Each set has its own copy of the
+// layout and partials, so every page can define the layout's blocks.
+// Fragments defined in the partials are executed from the shared base.
+type pages struct {
+	set  map[string]*template.Template
+	base *template.Template
+}
+
+func (p pages) ExecuteTemplate(w io.Writer, name string, data any) error {
+	if tpl, ok := p.set[name]; ok {
+		return tpl.ExecuteTemplate(w, name, data)
+	}
+	if p.base.Lookup(name) == nil || isShared(name) {
+		return fmt.Errorf("template %q not found", name)
+	}
+	return p.base.ExecuteTemplate(w, name, data)
+}
+
+// formField is the argument of the field partial.
+type formField struct {
+	Name, Type, Placeholder, Value string
+}
+
+var templateFuncs = template.FuncMap{
+	"field": func(name, typ, placeholder, value string) formField {
+		return formField{Name: name, Type: typ, Placeholder: placeholder, Value: value}
+	},
+	"userFields": func(l *i18n.Localizer, form UsersForm) userFields {
+		return userFields{L: l, Form: form}
+	},
+	"groupFields": func(l *i18n.Localizer, form GroupForm) groupFields {
+		return groupFields{L: l, Form: form}
+	},
+	"roleSelect": func(l *i18n.Localizer, selected string) roleSelect {
+		return roleSelect{L: l, Selected: selected}
+	},
+	"roles": func() []string {
+		return database.Roles
+	},
+	"userRow": func(l *i18n.Localizer, u database.User) UserRowData {
+		return UserRowData{User: &u, L: l}
+	},
+	"sortFields": func() []string {
+		return []string{"id", "name", "email", "age", "created_at", "updated_at"}
+	},
+	"locales": func() []*i18n.Localizer {
+		return i18n.Default().Locales()
+	},
+	// today bounds date inputs such as the birth date.
+	"today": func() string {
+		return database.Today().Format(time.DateOnly)
+	},
+}
+
+// userFields is the argument of the user-fields partial.
+type userFields struct {
+	L    *i18n.Localizer
+	Form UsersForm
+}
+
+// groupFields is the argument of the group-fields partial.
+type groupFields struct {
+	L    *i18n.Localizer
+	Form GroupForm
+}
+
+// roleSelect is the argument of the role-select partial.
+type roleSelect struct {
+	L        *i18n.Localizer
+	Selected string
+}
+
+// isShared reports whether a template file is available to every page
+// rather than being a page itself.
+func isShared(name string) bool {
+	return name == "layout.html" || strings.HasPrefix(name, "partials/")
+}
+
+// parseTemplates parses the embedded templates and then those matching
+// path, which replace embedded ones of the same name.
+func parseTemplates(path string) (Renderer, error) {
+	files := make(map[string]fs.FS)
+	collect := func(fsys fs.FS, patterns ...string) error {
+		for _, pattern := range patterns {
+			matches, err := fs.Glob(fsys, pattern)
+			if err != nil {
+				return err
+			}
+			for _, m := range matches {
+				files[m] = fsys
+			}
+		}
+		return nil
+	}
+
+	if err := collect(templates.FS, "*.html", "partials/*.html"); err != nil {
+		return nil, err
+	}
+	if dir, pattern := overrides(path); dir != "" {
+		if err := collect(os.DirFS(dir), pattern, "partials/*.html"); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parse := func(tpl *template.Template, name string) error {
+		src, err := fs.ReadFile(files[name], name)
+		if err != nil {
+			return err
+		}
+		_, err = tpl.New(name).Parse(string(src))
+		return err
+	}
+
+	base := template.New("").Funcs(templateFuncs)
+	for _, name := range names {
+		if isShared(name) {
+			if err := parse(base, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	set := make(map[string]*template.Template)
+	for _, name := range names {
+		if isShared(name) {
+			continue
+		}
+		tpl, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := parse(tpl, name); err != nil {
+			return nil, err
+		}
+		set[name] = tpl
+	}
+	return pages{set: set, base: base}, nil
+}
+
+// devRenderer re-parses the templates on every call so edits show up on the
+// next page load.
+type devRenderer struct {
+	path string
+}
+
+func (d devRenderer) ExecuteTemplate(w io.Writer, name string, data any) error {
+	tpl, err := parseTemplates(d.path)
+	if err != nil {
+		return fmt.Errorf("parse templates: %w", err)
+	}
+	return tpl.ExecuteTemplate(w, name, data)
+}
+
+func newRenderer(cfg config.TemplatesConfig) (Renderer, error) {
+	tpl, err := parseTemplates(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("parse templates: %w", err)
+	}
+	if cfg.Dev {
+		return devRenderer{path: cfg.Path}, nil
+	}
+	return tpl, nil
+}
+
+// overlayFS serves a file from upper when it exists there and from lower
+// otherwise.
+type overlayFS struct {
+	upper, lower fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	return o.lower.Open(name)
+}
+
+// newStaticHandler serves the embedded static/ directory, with files in
+// static/ under the templates override directory taking precedence.
+func newStaticHandler(cfg config.TemplatesConfig) http.Handler {
+	var files fs.FS
+	files, _ = fs.Sub(templates.FS, "static")
+	if dir, _ := overrides(cfg.Path); dir != "" {
+		files = overlayFS{upper: os.DirFS(filepath.Join(dir, "static")), lower: files}
+	}
+
+	cacheControl := "public, max-age=3600"
+	if cfg.Dev {
+		cacheControl = "no-cache"
+	}
+
+	fileServer := http.StripPrefix("/static/", http.FileServerFS(files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Don't list directories.
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goapp/internal/pkg/config"
+	"goapp/internal/pkg/database"
+	"goapp/internal/pkg/i18n"
+)
+
+func TestNewRenderer_Embedded(t *testing.T) {
+	tpl, err := newRenderer(config.TemplatesConfig{})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	data := UsersPageData{Error: "boom", Flash: &Flash{Kind: "success", Message: "User Mahir created"}, LiveSearch: true}
+	if err := tpl.ExecuteTemplate(&buf, "users.html", data); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "<title>Users</title>") || !strings.Contains(out, `class="error" role="alert">boom`) {
+		t.Fatalf("expected layout with error box, got %q", out)
+	}
+	if !strings.Contains(out, `flash-success" role="status">User Mahir created`) {
+		t.Fatalf("expected flash message, got %q", out)
+	}
+	if !strings.Contains(out, `data-target="users-content" data-live>`) {
+		t.Fatalf("expected a live search form, got %q", out)
+	}
+	if !strings.Contains(out, `sort=updated_at`) {
+		t.Fatalf("expected the users to be sortable by update time, got %q", out)
+	}
+}
+
+func TestNewRenderer_Fragments(t *testing.T) {
+	tpl, err := newRenderer(config.TemplatesConfig{})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	data := UserRowData{User: &database.User{ID: 7, Name: "Mahir", Email: "mahir@test.com", BirthDate: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)}, L: i18n.Default().Match("en")}
+	if err := tpl.ExecuteTemplate(&buf, "user-row", data); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	out := strings.TrimSpace(buf.String())
+	if !strings.HasPrefix(out, `<tr id="user-7">`) || strings.Contains(out, "<html") {
+		t.Fatalf("expected a bare row, got %q", out)
+	}
+
+	if err := tpl.ExecuteTemplate(&buf, "layout.html", data); err == nil {
+		t.Fatalf("expected shared files not to be executable")
+	}
+}
+
+func TestNewRenderer_OverrideAndDevMode(t *testing.T) {
+	dir := t.TempDir()
+	edit := filepath.Join(dir, "edit.html")
+	if err := os.WriteFile(edit, []byte("custom v1"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	tpl, err := newRenderer(config.TemplatesConfig{Path: dir, Dev: true})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	render := func(name string) string {
+		var buf bytes.Buffer
+		if err := tpl.ExecuteTemplate(&buf, name, UsersPageData{}); err != nil {
+			t.Fatalf("execute %s: %v", name, err)
+		}
+		return buf.String()
+	}
+
+	if got := render("edit.html"); got != "custom v1" {
+		t.Fatalf("expected override, got %q", got)
+	}
+	if !strings.Contains(render("users.html"), "<h1>Users</h1>") {
+		t.Fatalf("expected embedded users.html to remain")
+	}
+
+	if err := os.WriteFile(edit, []byte("custom v2"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	if got := render("edit.html"); got != "custom v2" {
+		t.Fatalf("expected dev mode to pick up the edit, got %q", got)
+	}
+}
+
+func TestNewRenderer_InvalidOverrideReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "users.html"), []byte("{{.Broken"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	if _, err := newRenderer(config.TemplatesConfig{Path: dir}); err == nil {
+		t.Fatalf("expected parse error, got nil")
+	}
+}
+
+func TestStaticHandler(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "static"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "static", "extra.js"), []byte("// extra"), 0o644); err != nil {
+		t.Fatalf("write asset: %v", err)
+	}
+
+	h := newStaticHandler(config.TemplatesConfig{Path: dir})
+
+	for path, want := range map[string]int{
+		"/static/app.css":  http.StatusOK,
+		"/static/extra.js": http.StatusOK,
+		"/static/":         http.StatusNotFound,
+		"/static/nope.css": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package attributes describes the custom fields administrators define for
+// users in the config, and checks user values against them.
+package attributes
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Type string
+
+const (
+	String Type = "string"
+	Number Type = "number"
+	Bool   Type = "bool"
+	Date   Type = "date"
+)
+
+var (
+	ErrUnknown  = errors.New("unknown attribute")
+	ErrRequired = errors.New("attribute is required")
+	ErrInvalid  = errors.New("invalid attribute value")
+)
+
+// Error is a problem with the value of one attribute.
+type Error struct {
+	Name string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Def defines one attribute. Name is used in forms, CSV columns and JSON;
+// Label is shown in the UI and defaults to Name.
+type Def struct {
+	Name     string `mapstructure:"name"`
+	Label    string `mapstructure:"label"`
+	Type     Type   `mapstructure:"type"`
+	Required bool   `mapstructure:"required"`
+}
+
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Check reports a problem with the definition itself.
+func (d Def) Check() error {
+	if !nameRegex.MatchString(d.Name) {
+		return fmt.Errorf("name %q must be lower case letters, digits and underscores, starting with a letter", d.Name)
+	}
+	switch d.Type {
+	case String, Number, Bool, Date:
+		return nil
+	}
+	return fmt.Errorf("%s: unknown type %q (expected string, number, bool or date)", d.Name, d.Type)
+}
+
+func (d Def) DisplayLabel() string {
+	if d.Label != "" {
+		return d.Label
+	}
+	return d.Name
+}
+
+// convert returns v in the canonical form of the attribute's type: a
+// string, a float64, a bool or a 2006-01-02 date string. Strings, as they
+// come from forms and CSV files, are parsed for the other types.
+func (d Def) convert(v any) (any, error) {
+	s, isString := v.(string)
+	if isString {
+		s = strings.TrimSpace(s)
+	}
+
+	switch d.Type {
+	case String:
+		if isString {
+			return s, nil
+		}
+	case Number:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int:
+			return float64(n), nil
+		case string:
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f, nil
+			}
+		}
+	case Bool:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			if s == "on" {
+				return true, nil
+			}
+			if parsed, err := strconv.ParseBool(s); err == nil {
+				return parsed, nil
+			}
+		}
+	case Date:
+		if isString {
+			if t, err := time.Parse(time.DateOnly, s); err == nil {
+				return t.Format(time.DateOnly), nil
+			}
+		}
+	}
+	return nil, ErrInvalid
+}
+
+// Defs is the ordered list of attributes from the config.
+type Defs []Def
+
+func (ds Defs) Lookup(name string) (Def, bool) {
+	for _, d := range ds {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Def{}, false
+}
+
+// Normalize checks values against the definitions and returns them in
+// their canonical form. Empty strings leave an attribute unset. Problems
+// are reported as *Error, for the first attribute in definition order and
+// then for unknown names in alphabetical order.
+func (ds Defs) Normalize(values map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(values))
+	for _, d := range ds {
+		v, ok := values[d.Name]
+		if s, isString := v.(string); ok && isString && strings.TrimSpace(s) == "" {
+			ok = false
+		}
+		if !ok || v == nil {
+			if d.Required {
+				return nil, &Error{Name: d.Name, Err: ErrRequired}
+			}
+			continue
+		}
+
+		converted, err := d.convert(v)
+		if err != nil {
+			return nil, &Error{Name: d.Name, Err: err}
+		}
+		out[d.Name] = converted
+	}
+
+	var unknown []string
+	for name := range values {
+		if _, ok := ds.Lookup(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, &Error{Name: unknown[0], Err: ErrUnknown}
+	}
+
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// Format renders a value for a form field or a CSV cell.
+func Format(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
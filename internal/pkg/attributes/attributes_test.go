@@ -0,0 +1,61 @@
+package attributes
+
+import (
+	"errors"
+	"testing"
+)
+
+var testDefs = Defs{
+	{Name: "cost_center", Type: String, Required: true},
+	{Name: "fte", Type: Number},
+	{Name: "remote", Type: Bool},
+	{Name: "start_date", Type: Date},
+}
+
+func TestNormalize_ConvertsStrings(t *testing.T) {
+	got, err := testDefs.Normalize(map[string]any{
+		"cost_center": " CC-1 ",
+		"fte":         "0.5",
+		"remote":      "on",
+		"start_date":  "2024-05-01",
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if got["cost_center"] != "CC-1" || got["fte"] != 0.5 || got["remote"] != true || got["start_date"] != "2024-05-01" {
+		t.Fatalf("unexpected values %#v", got)
+	}
+}
+
+func TestNormalize_Errors(t *testing.T) {
+	tests := []struct {
+		values map[string]any
+		name   string
+		err    error
+	}{
+		{map[string]any{"fte": 1.0}, "cost_center", ErrRequired},
+		{map[string]any{"cost_center": "", "fte": 1.0}, "cost_center", ErrRequired},
+		{map[string]any{"cost_center": "CC", "fte": "lots"}, "fte", ErrInvalid},
+		{map[string]any{"cost_center": "CC", "start_date": "01/05/2024"}, "start_date", ErrInvalid},
+		{map[string]any{"cost_center": "CC", "shoe_size": "44"}, "shoe_size", ErrUnknown},
+	}
+	for _, tt := range tests {
+		_, err := testDefs.Normalize(tt.values)
+		var attrErr *Error
+		if !errors.As(err, &attrErr) || attrErr.Name != tt.name || !errors.Is(err, tt.err) {
+			t.Fatalf("%v: expected %v for %s, got %v", tt.values, tt.err, tt.name, err)
+		}
+	}
+}
+
+func TestDefCheck(t *testing.T) {
+	if err := (Def{Name: "Cost Center", Type: String}).Check(); err == nil {
+		t.Fatalf("expected error for invalid name")
+	}
+	if err := (Def{Name: "fte", Type: "float"}).Check(); err == nil {
+		t.Fatalf("expected error for unknown type")
+	}
+	if err := testDefs[1].Check(); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+}
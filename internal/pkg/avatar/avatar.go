@@ -0,0 +1,136 @@
+// Package avatar turns uploaded pictures into the square JPEG images shown
+// as user avatars.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+
+	// Register the formats accepted besides JPEG.
+	_ "image/gif"
+	_ "image/png"
+)
+
+var (
+	ErrUnsupportedType = errors.New("not a JPEG, PNG or GIF image")
+	ErrInvalidImage    = errors.New("the image can't be read")
+	ErrTooManyPixels   = errors.New("the image has too many pixels")
+)
+
+// ContentType is the type of the images Process returns.
+const ContentType = "image/jpeg"
+
+// Key names the stored avatar of the user with the given id at version,
+// or its thumbnail. Every version is stored under new keys, so the files
+// can be cached for as long as the user keeps that avatar.
+func Key(userID int64, version string, thumb bool) string {
+	if thumb {
+		return fmt.Sprintf("avatars/%d/%s-thumb.jpg", userID, version)
+	}
+	return fmt.Sprintf("avatars/%d/%s.jpg", userID, version)
+}
+
+// Options control Process.
+type Options struct {
+	// MaxPixels caps width times height of the upload.
+	MaxPixels int
+	// Size and ThumbSize are the edges of the images returned.
+	Size      int
+	ThumbSize int
+}
+
+// Images are the encoded avatar and its thumbnail.
+type Images struct {
+	Full  []byte
+	Thumb []byte
+}
+
+// Process checks that data is a JPEG, PNG or GIF image by sniffing its
+// content rather than trusting the name or type the client sent, then
+// crops the middle square and scales it to both sizes.
+func Process(data []byte, opts Options) (*Images, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	// The header is enough to refuse an image that would take too much
+	// memory to decode.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > int64(opts.MaxPixels) {
+		return nil, ErrTooManyPixels
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	full := Square(src, opts.Size)
+	var images Images
+	if images.Full, err = encode(full); err != nil {
+		return nil, err
+	}
+	if images.Thumb, err = encode(Square(full, opts.ThumbSize)); err != nil {
+		return nil, err
+	}
+	return &images, nil
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Square crops the largest centered square out of src and scales it to
+// size by size pixels. Each pixel is the average of the source pixels it
+// covers, and transparent areas become white since JPEG has no alpha.
+func Square(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for dy := range size {
+		sy0 := y0 + dy*side/size
+		sy1 := max(y0+(dy+1)*side/size, sy0+1)
+		for dx := range size {
+			sx0 := x0 + dx*side/size
+			sx1 := max(x0+(dx+1)*side/size, sx0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			// The channels are premultiplied, so adding the missing
+			// coverage composites the pixel over white.
+			white := 0xffff - a/n
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8((r/n + white) >> 8),
+				G: uint8((g/n + white) >> 8),
+				B: uint8((bl/n + white) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,129 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"goapp/internal/pkg/storage"
+)
+
+func pngImage(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+var opts = Options{MaxPixels: 1_000_000, Size: 32, ThumbSize: 8}
+
+func TestProcess(t *testing.T) {
+	// A wide image: red on the left, blue on the right, with the middle
+	// square split between them.
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := range 100 {
+		for x := range 300 {
+			c := color.RGBA{R: 0xff, A: 0xff}
+			if x >= 150 {
+				c = color.RGBA{B: 0xff, A: 0xff}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	images, err := Process(pngImage(t, src), opts)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	for _, tt := range []struct {
+		data []byte
+		size int
+	}{{images.Full, 32}, {images.Thumb, 8}} {
+		img, err := jpeg.Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("expected a JPEG, got %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != tt.size || b.Dy() != tt.size {
+			t.Fatalf("expected %dx%d, got %v", tt.size, tt.size, b)
+		}
+		left, _, _, _ := img.At(1, tt.size/2).RGBA()
+		_, _, right, _ := img.At(tt.size-2, tt.size/2).RGBA()
+		if left < 0xd000 || right < 0xd000 {
+			t.Fatalf("expected red left and blue right of the middle, got %x %x", left, right)
+		}
+	}
+}
+
+func TestProcess_Rejects(t *testing.T) {
+	big := pngImage(t, image.NewGray(image.Rect(0, 0, 2000, 1000)))
+	truncated := pngImage(t, image.NewGray(image.Rect(0, 0, 10, 10)))[:40]
+
+	for name, tt := range map[string]struct {
+		data []byte
+		want error
+	}{
+		"text":      {[]byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"), ErrUnsupportedType},
+		"empty":     {nil, ErrUnsupportedType},
+		"truncated": {truncated, ErrInvalidImage},
+		"too big":   {big, ErrTooManyPixels},
+	} {
+		if _, err := Process(tt.data, opts); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestSquare_TransparentBecomesWhite(t *testing.T) {
+	img := Square(image.NewNRGBA(image.Rect(0, 0, 10, 10)), 4)
+	if c := img.RGBAAt(2, 2); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("expected white, got %v", c)
+	}
+}
+
+func TestSquare_Upscales(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.SetRGBA(0, 0, color.RGBA{R: 0xff, A: 0xff})
+	img := Square(src, 4)
+	if c := img.RGBAAt(1, 1); c.R != 0xff || c.G != 0 {
+		t.Fatalf("expected red, got %v", c)
+	}
+	if c := img.RGBAAt(3, 3); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("expected the transparent corner to be white, got %v", c)
+	}
+}
+
+func TestSaveAndDelete(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	images := &Images{Full: []byte("full"), Thumb: []byte("thumb")}
+
+	version, err := Save(t.Context(), store, 7, images)
+	if err != nil || len(version) != 16 {
+		t.Fatalf("expected a version, got %q %v", version, err)
+	}
+	if again, _ := Save(t.Context(), store, 7, images); again != version {
+		t.Fatalf("expected the same version for the same images, got %q and %q", version, again)
+	}
+	obj, err := store.Get(t.Context(), Key(7, version, true))
+	if err != nil {
+		t.Fatalf("expected the thumbnail, got %v", err)
+	}
+	obj.Close()
+
+	if err := Delete(t.Context(), store, 7, version); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	for _, thumb := range []bool{false, true} {
+		if _, err := store.Get(t.Context(), Key(7, version, thumb)); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"goapp/internal/pkg/storage"
+)
+
+// Save stores images as the avatar of the user with the given id and
+// returns their version, which is derived from the content so uploading
+// the same picture again changes nothing.
+func Save(ctx context.Context, store storage.Store, userID int64, images *Images) (string, error) {
+	sum := sha256.Sum256(images.Full)
+	version := hex.EncodeToString(sum[:8])
+
+	for _, f := range []struct {
+		data  []byte
+		thumb bool
+	}{{images.Full, false}, {images.Thumb, true}} {
+		if err := store.Put(ctx, Key(userID, version, f.thumb), bytes.NewReader(f.data), int64(len(f.data)), ContentType); err != nil {
+			return "", err
+		}
+	}
+	return version, nil
+}
+
+// Delete removes the stored files of version of the user's avatar.
+func Delete(ctx context.Context, store storage.Store, userID int64, version string) error {
+	return errors.Join(
+		store.Delete(ctx, Key(userID, version, false)),
+		store.Delete(ctx, Key(userID, version, true)),
+	)
+}
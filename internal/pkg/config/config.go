@@ -1,64 +1,436 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"goapp/internal/pkg/attributes"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	Templates TemplatesConfig
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Templates TemplatesConfig `mapstructure:"templates"`
+	HTTP      HTTPConfig      `mapstructure:"http"`
+	Log       LogConfig       `mapstructure:"log"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+	Health    HealthConfig    `mapstructure:"health"`
+	Limits    LimitsConfig    `mapstructure:"limits"`
+	Session   SessionConfig   `mapstructure:"session"`
+	Users     UsersConfig     `mapstructure:"users"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	// Features holds named on/off switches that can be flipped without a
+	// restart. live_search makes the users page search as the user types.
+	Features map[string]bool `mapstructure:"features"`
 }
 
 type ServerConfig struct {
-	Host string
-	Port int
+	Host string    `mapstructure:"host"`
+	Port int       `mapstructure:"port"`
+	TLS  TLSConfig `mapstructure:"tls"`
+	// ShutdownDrain is how long the server keeps serving with /readyz
+	// failing before it stops accepting connections.
+	ShutdownDrain time.Duration `mapstructure:"shutdown_drain"`
+	// ShutdownTimeout bounds the whole shutdown, drain included.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
 }
 
+func (s ServerConfig) Address() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+type TLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"`
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientAuth is one of none, request, require, verify_if_given or
+	// require_and_verify and only matters when ClientCAFile is set.
+	ClientAuth string `mapstructure:"client_auth"`
+	// RedirectPort, when non-zero, starts a plain HTTP listener on that
+	// port which redirects every request to the HTTPS server.
+	RedirectPort int `mapstructure:"redirect_port"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// DatabaseConfig takes the DSN in one of three ways: dsn itself, dsn_file
+// naming a file that holds it (a Docker or Kubernetes secret), or the
+// structured fields from which Load assembles it.
 type DatabaseConfig struct {
-	DSN string
+	DSN     string `mapstructure:"dsn" secret:"true"`
+	DSNFile string `mapstructure:"dsn_file"`
+
+	Host         string `mapstructure:"host"`
+	Port         int    `mapstructure:"port"`
+	User         string `mapstructure:"user"`
+	Password     string `mapstructure:"password" secret:"true"`
+	PasswordFile string `mapstructure:"password_file"`
+	DBName       string `mapstructure:"dbname"`
+	// Params are extra DSN parameters in query string form.
+	Params string `mapstructure:"params"`
+
+	AutoMigrate bool `mapstructure:"auto_migrate"`
 }
 
 type TemplatesConfig struct {
-	Path string
+	// Path optionally points at a directory whose *.html files replace
+	// the embedded templates of the same name and whose static/ files
+	// replace embedded assets. A glob such as templates/*.html selects
+	// the templates explicitly.
+	Path string `mapstructure:"path"`
+	// Dev re-parses the templates on every request.
+	Dev bool `mapstructure:"dev"`
 }
 
-func Load() (*Config, error) {
-	v := viper.New()
+type HTTPConfig struct {
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	MaxHeaderBytes    int           `mapstructure:"max_header_bytes"`
+	MaxBodyBytes      int64         `mapstructure:"max_body_bytes"`
+	Headers           HeadersConfig `mapstructure:"headers"`
+}
 
-	v.SetConfigName("config")
-	v.SetConfigType("yaml")
-	v.AddConfigPath(".")
+type HeadersConfig struct {
+	ContentSecurityPolicy string        `mapstructure:"content_security_policy"`
+	FrameOptions          string        `mapstructure:"frame_options"`
+	ReferrerPolicy        string        `mapstructure:"referrer_policy"`
+	HSTSMaxAge            time.Duration `mapstructure:"hsts_max_age"`
+	HSTSIncludeSubdomains bool          `mapstructure:"hsts_include_subdomains"`
+}
+
+type LogConfig struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of otlp, stdout or none.
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	ServiceName string  `mapstructure:"service_name"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+type HealthConfig struct {
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type LimitsConfig struct {
+	DefaultPageSize int `mapstructure:"default_page_size"`
+	MaxPageSize     int `mapstructure:"max_page_size"`
+}
 
+type SessionConfig struct {
+	// Secret signs session cookies. Instances behind a load balancer must
+	// share it; when empty a random one is generated at startup.
+	Secret     string `mapstructure:"secret" secret:"true"`
+	SecretFile string `mapstructure:"secret_file"`
+}
+
+type UsersConfig struct {
+	// Attributes are custom fields stored with every user, in the order
+	// forms and exports show them.
+	Attributes attributes.Defs `mapstructure:"attributes"`
+	Avatar     AvatarConfig    `mapstructure:"avatar"`
+}
+
+type AvatarConfig struct {
+	// MaxBytes caps the size of an uploaded image.
+	MaxBytes int64 `mapstructure:"max_bytes"`
+	// MaxPixels caps width times height, so a small file can't decode
+	// into a huge image.
+	MaxPixels int `mapstructure:"max_pixels"`
+	// Size and ThumbSize are the edges of the square images stored.
+	Size      int `mapstructure:"size"`
+	ThumbSize int `mapstructure:"thumb_size"`
+}
+
+// StorageConfig selects where uploaded files such as avatars are kept.
+type StorageConfig struct {
+	// Driver is local or s3.
+	Driver string `mapstructure:"driver"`
+	// Path is the directory the local driver keeps files in.
+	Path string   `mapstructure:"path"`
+	S3   S3Config `mapstructure:"s3"`
+}
+
+// S3Config points at an S3 bucket or a service speaking the same API,
+// such as MinIO.
+type S3Config struct {
+	Endpoint string `mapstructure:"endpoint"`
+	Region   string `mapstructure:"region"`
+	Bucket   string `mapstructure:"bucket"`
+	// PathStyle addresses objects as endpoint/bucket/key rather than
+	// bucket.endpoint/key, which most self-hosted services need.
+	PathStyle     bool   `mapstructure:"path_style"`
+	AccessKey     string `mapstructure:"access_key"`
+	SecretKey     string `mapstructure:"secret_key" secret:"true"`
+	SecretKeyFile string `mapstructure:"secret_key_file"`
+}
+
+// setDefaults registers a default for every key. Besides providing the
+// values, this is what makes viper consult the environment for a key
+// during Unmarshal, so keys without a sensible default still get an empty
+// one here.
+func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.host", "localhost")
 	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.shutdown_drain", 5*time.Second)
+	v.SetDefault("server.shutdown_timeout", 20*time.Second)
+	v.SetDefault("server.tls.cert_file", "")
+	v.SetDefault("server.tls.key_file", "")
+	v.SetDefault("server.tls.client_ca_file", "")
+	v.SetDefault("server.tls.client_auth", "require_and_verify")
+	v.SetDefault("server.tls.redirect_port", 0)
+
+	v.SetDefault("database.dsn", "")
+	v.SetDefault("database.dsn_file", "")
+	v.SetDefault("database.host", "")
+	v.SetDefault("database.port", 3306)
+	v.SetDefault("database.user", "")
+	v.SetDefault("database.password", "")
+	v.SetDefault("database.password_file", "")
+	v.SetDefault("database.dbname", "")
+	v.SetDefault("database.params", "parseTime=true")
+	v.SetDefault("database.auto_migrate", true)
 
-	v.SetDefault("templates.path", "templates/*.html")
+	v.SetDefault("templates.path", "")
+	v.SetDefault("templates.dev", false)
+
+	v.SetDefault("http.read_timeout", 15*time.Second)
+	v.SetDefault("http.read_header_timeout", 5*time.Second)
+	v.SetDefault("http.write_timeout", 30*time.Second)
+	v.SetDefault("http.idle_timeout", 60*time.Second)
+	v.SetDefault("http.max_header_bytes", 1<<20)
+	v.SetDefault("http.max_body_bytes", 1<<20)
+	v.SetDefault("http.headers.content_security_policy",
+		"default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; frame-ancestors 'none'; base-uri 'self'; form-action 'self'")
+	v.SetDefault("http.headers.frame_options", "DENY")
+	v.SetDefault("http.headers.referrer_policy", "strict-origin-when-cross-origin")
+	v.SetDefault("http.headers.hsts_max_age", 365*24*time.Hour)
+	v.SetDefault("http.headers.hsts_include_subdomains", false)
+
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "text")
+
+	v.SetDefault("metrics.enabled", true)
+	v.SetDefault("metrics.path", "/metrics")
+
+	v.SetDefault("tracing.exporter", "none")
+	v.SetDefault("tracing.endpoint", "")
+	v.SetDefault("tracing.insecure", false)
+	v.SetDefault("tracing.service_name", "goapp")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	v.SetDefault("health.timeout", 2*time.Second)
+
+	v.SetDefault("users.attributes", []any{})
+	v.SetDefault("users.avatar.max_bytes", 5<<20)
+	v.SetDefault("users.avatar.max_pixels", 25_000_000)
+	v.SetDefault("users.avatar.size", 256)
+	v.SetDefault("users.avatar.thumb_size", 64)
+
+	v.SetDefault("storage.driver", "local")
+	v.SetDefault("storage.path", "data")
+	v.SetDefault("storage.s3.endpoint", "https://s3.amazonaws.com")
+	v.SetDefault("storage.s3.region", "us-east-1")
+	v.SetDefault("storage.s3.bucket", "")
+	v.SetDefault("storage.s3.path_style", false)
+	v.SetDefault("storage.s3.access_key", "")
+	v.SetDefault("storage.s3.secret_key", "")
+	v.SetDefault("storage.s3.secret_key_file", "")
+
+	v.SetDefault("limits.default_page_size", 10)
+	v.SetDefault("limits.max_page_size", 100)
+
+	v.SetDefault("session.secret", "")
+	v.SetDefault("session.secret_file", "")
+
+	v.SetDefault("features.live_search", true)
+}
+
+// ConfigEnv names the environment variable that selects the config file
+// when --config is not given.
+const ConfigEnv = "GOAPP_CONFIG"
+
+// ProfileEnv names the environment variable that selects the profile when
+// --profile is not given.
+const ProfileEnv = "GOAPP_PROFILE"
+
+// SearchPaths are the directories searched for config.yaml when no file is
+// given explicitly, in order.
+var SearchPaths = []string{".", "$HOME/.config/goapp", "/etc/goapp"}
+
+type loadOptions struct {
+	file    string
+	profile string
+	flags   *pflag.FlagSet
+}
+
+type Option func(*loadOptions)
+
+// WithFile reads the config from path instead of searching for it. A
+// missing file is then an error.
+func WithFile(path string) Option {
+	return func(o *loadOptions) { o.file = path }
+}
+
+// WithProfile layers config.<profile>.yaml, found next to the base config
+// file, over it. The profile file must exist.
+func WithProfile(name string) Option {
+	return func(o *loadOptions) { o.profile = name }
+}
+
+// WithFlags applies flags registered by RegisterFlags on top of the file
+// and environment. Only flags that were set on the command line count.
+func WithFlags(fs *pflag.FlagSet) Option {
+	return func(o *loadOptions) { o.flags = fs }
+}
+
+// Load builds the configuration from, in decreasing precedence, command
+// line flags, environment variables, the profile file, the config file and
+// defaults.
+func Load(opts ...Option) (*Config, error) {
+	o := &loadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cfg, _, err := load(o)
+	return cfg, err
+}
+
+// load also returns the config files that were read, base file first.
+func load(o *loadOptions) (*Config, []string, error) {
+	v, files, err := newViper(o)
+	if err != nil {
+		return nil, nil, err
+	}
+	source := strings.Join(files, ", ")
+
+	cfg := &Config{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, nil, fmt.Errorf("decode config %s: %w", source, err)
+	}
+
+	problems := unknownKeys(v.AllKeys())
+	problems = append(problems, cfg.Database.resolve()...)
+	problems = append(problems, cfg.Session.resolve()...)
+	problems = append(problems, cfg.Storage.S3.resolve()...)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, nil, &ValidationError{File: source, Problems: problems}
+	}
+
+	return cfg, files, nil
+}
+
+func newViper(o *loadOptions) (*viper.Viper, []string, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+
+	setDefaults(v)
 
 	v.AutomaticEnv()
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
-	_ = v.ReadInConfig()
-
-	cfg := &Config{
-		Server: ServerConfig{
-			Host: v.GetString("server.host"),
-			Port: v.GetInt("server.port"),
-		},
-		Database: DatabaseConfig{
-			DSN: v.GetString("database.dsn"),
-		},
-		Templates: TemplatesConfig{
-			Path: v.GetString("templates.path"),
-		},
+	file, profile := o.file, o.profile
+	if o.flags != nil {
+		if f := o.flags.Lookup(configFlag); f != nil && f.Changed {
+			file = f.Value.String()
+		}
+		if f := o.flags.Lookup(profileFlag); f != nil && f.Changed {
+			profile = f.Value.String()
+		}
+		if err := bindFlags(v, o.flags); err != nil {
+			return nil, nil, err
+		}
+	}
+	if file == "" {
+		file = os.Getenv(ConfigEnv)
+	}
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+
+	var files []string
+	if file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("read config %s: %w", file, err)
+		}
+		files = append(files, file)
+	} else {
+		v.SetConfigName("config")
+		for _, p := range SearchPaths {
+			v.AddConfigPath(os.ExpandEnv(p))
+		}
+
+		if err := v.ReadInConfig(); err != nil {
+			var notFound viper.ConfigFileNotFoundError
+			if !errors.As(err, &notFound) {
+				return nil, nil, fmt.Errorf("read config: %w", err)
+			}
+		} else {
+			files = append(files, v.ConfigFileUsed())
+		}
 	}
 
-	if cfg.Database.DSN == "" {
-		return nil, fmt.Errorf("database.dsn is empty (set in config.yaml or env DATABASE_DSN)")
+	if profile == "" {
+		return v, files, nil
 	}
 
-	return cfg, nil
+	pf, err := profileFile(profile, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	v.SetConfigFile(pf)
+	if err := v.MergeInConfig(); err != nil {
+		return nil, nil, fmt.Errorf("read profile %s: %w", pf, err)
+	}
+
+	return v, append(files, pf), nil
+}
+
+// profileFile locates the file for profile: next to the base config file,
+// or in the search paths when there is none.
+func profileFile(profile string, files []string) (string, error) {
+	if profile == "." || profile == ".." || strings.ContainsAny(profile, `/\`) {
+		return "", fmt.Errorf("invalid profile name %q", profile)
+	}
+
+	if len(files) > 0 {
+		base := files[0]
+		ext := filepath.Ext(base)
+		pf := strings.TrimSuffix(base, ext) + "." + profile + ext
+		if _, err := os.Stat(pf); err != nil {
+			return "", fmt.Errorf("profile %s: %w", profile, err)
+		}
+		return pf, nil
+	}
+
+	for _, p := range SearchPaths {
+		pf := filepath.Join(os.ExpandEnv(p), "config."+profile+".yaml")
+		if _, err := os.Stat(pf); err == nil {
+			return pf, nil
+		}
+	}
+	return "", fmt.Errorf("profile %s: config.%s.yaml not found in %s", profile, profile, strings.Join(SearchPaths, ", "))
 }
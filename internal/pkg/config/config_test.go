@@ -1,11 +1,32 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
+// chdirWithTemplates runs the test from a temp dir holding a template, so
+// templates.path can point at it and a config.yaml can be written.
+func chdirWithTemplates(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "users.html"), []byte("ok"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	t.Chdir(dir)
+	return dir
+}
+
 func TestLoad_FromEnv(t *testing.T) {
+	chdirWithTemplates(t)
 	t.Setenv("DATABASE_DSN", "root:root@tcp(localhost:3306)/myapp?parseTime=true")
 	t.Setenv("SERVER_HOST", "127.0.0.1")
 	t.Setenv("SERVER_PORT", "9090")
@@ -44,3 +65,256 @@ func TestLoad_EmptyDSN_ReturnsError(t *testing.T) {
 		t.Fatalf("expected nil config on error")
 	}
 }
+
+func TestLoad_HTTPDefaults(t *testing.T) {
+	chdirWithTemplates(t)
+	t.Setenv("DATABASE_DSN", "root:root@tcp(localhost:3306)/myapp?parseTime=true")
+	t.Setenv("HTTP_READ_TIMEOUT", "3s")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if cfg.HTTP.ReadTimeout != 3*time.Second {
+		t.Fatalf("expected read timeout 3s, got %s", cfg.HTTP.ReadTimeout)
+	}
+
+	if cfg.HTTP.WriteTimeout != 30*time.Second {
+		t.Fatalf("expected default write timeout 30s, got %s", cfg.HTTP.WriteTimeout)
+	}
+
+	if cfg.HTTP.MaxBodyBytes != 1<<20 {
+		t.Fatalf("expected default max body 1MiB, got %d", cfg.HTTP.MaxBodyBytes)
+	}
+
+	if cfg.HTTP.Headers.FrameOptions != "DENY" {
+		t.Fatalf("expected X-Frame-Options DENY, got %q", cfg.HTTP.Headers.FrameOptions)
+	}
+}
+
+func TestLoad_ReportsAllProblems(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	os.Unsetenv("DATABASE_DSN")
+
+	yaml := `
+server:
+  port: 70000
+  prot: 8080
+database:
+  dsn: "not a dsn"
+log:
+  level: "loud"
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := Load()
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+
+	keys := map[string]bool{}
+	for _, p := range verr.Problems {
+		keys[p.Key] = true
+	}
+	for _, want := range []string{"server.port", "server.prot", "database.dsn", "log.level"} {
+		if !keys[want] {
+			t.Fatalf("expected a problem for %s, got %v", want, err)
+		}
+	}
+}
+
+func TestLoad_UserAttributes(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	t.Setenv("DATABASE_DSN", "root:root@tcp(localhost:3306)/myapp")
+
+	yaml := `
+users:
+  attributes:
+    - name: cost_center
+      label: "Cost center"
+      type: string
+      required: true
+    - name: remote
+      type: bool
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	attrs := cfg.Users.Attributes
+	if len(attrs) != 2 || attrs[0].Label != "Cost center" || !attrs[0].Required || attrs[1].Type != "bool" {
+		t.Fatalf("unexpected attributes %+v", attrs)
+	}
+
+	yaml += `
+    - name: remote
+      type: float
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err = Load()
+	if err == nil || !strings.Contains(err.Error(), `users.attributes[2]: remote: unknown type "float"`) ||
+		!strings.Contains(err.Error(), `users.attributes[2]: duplicate name "remote"`) {
+		t.Fatalf("expected attribute problems, got %v", err)
+	}
+}
+
+func TestLoad_Features(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	t.Setenv("DATABASE_DSN", "root:root@tcp(localhost:3306)/myapp")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !cfg.Features["live_search"] {
+		t.Fatalf("expected live_search to be on by default, got %v", cfg.Features)
+	}
+
+	yaml := `
+features:
+  live_search: false
+  other: true
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if cfg.Features["live_search"] || !cfg.Features["other"] {
+		t.Fatalf("expected the file's switches, got %v", cfg.Features)
+	}
+}
+
+func TestLoad_MalformedFile(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	t.Setenv("DATABASE_DSN", "root:root@tcp(localhost:3306)/myapp")
+
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("server:\n  port: [\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "read config") {
+		t.Fatalf("expected read error, got %v", err)
+	}
+}
+
+func TestLoad_ProfileOverridesBase(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	os.Unsetenv("DATABASE_DSN")
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), `
+server:
+  port: 7000
+database:
+  dsn: "root:root@tcp(localhost:3306)/myapp"
+log:
+  level: "debug"
+`)
+	writeFile(t, filepath.Join(dir, "config.prod.yaml"), `
+log:
+  level: "warn"
+  format: "json"
+`)
+	t.Setenv(ProfileEnv, "prod")
+
+	cfg, files, err := load(&loadOptions{})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if cfg.Log.Level != "warn" || cfg.Log.Format != "json" {
+		t.Fatalf("expected profile values, got %+v", cfg.Log)
+	}
+	if cfg.Server.Port != 7000 {
+		t.Fatalf("expected base value 7000, got %d", cfg.Server.Port)
+	}
+	if len(files) != 2 || filepath.Base(files[1]) != "config.prod.yaml" {
+		t.Fatalf("expected base and profile files, got %v", files)
+	}
+}
+
+func TestLoad_MissingProfile(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	t.Setenv("DATABASE_DSN", "root:root@tcp(localhost:3306)/myapp")
+	writeFile(t, filepath.Join(dir, "config.yaml"), "log:\n  level: info\n")
+
+	if _, err := Load(WithProfile("staging")); err == nil || !strings.Contains(err.Error(), "profile staging") {
+		t.Fatalf("expected missing profile error, got %v", err)
+	}
+	if _, err := Load(WithProfile("../etc")); err == nil {
+		t.Fatalf("expected error for profile name with a path")
+	}
+}
+
+func TestLoad_Storage(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	t.Setenv("DATABASE_DSN", "root:root@tcp(localhost:3306)/myapp")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if cfg.Storage.Driver != "local" || cfg.Storage.Path != "data" || cfg.Users.Avatar.Size != 256 || cfg.Users.Avatar.ThumbSize != 64 {
+		t.Fatalf("unexpected defaults %+v %+v", cfg.Storage, cfg.Users.Avatar)
+	}
+
+	secret := filepath.Join(dir, "s3_secret")
+	if err := os.WriteFile(secret, []byte("shh\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	yaml := `
+storage:
+  driver: s3
+  s3:
+    endpoint: "http://localhost:9000"
+    bucket: goapp
+    path_style: true
+    access_key: goapp
+    secret_key_file: "` + secret + `"
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if s3 := cfg.Storage.S3; s3.SecretKey != "shh" || s3.Region != "us-east-1" || !s3.PathStyle {
+		t.Fatalf("unexpected s3 config %+v", s3)
+	}
+
+	yaml = `
+storage:
+  driver: s3
+  s3:
+    endpoint: "localhost:9000"
+    access_key: goapp
+users:
+  avatar:
+    thumb_size: 512
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	_, err = Load()
+	for _, want := range []string{"storage.s3.endpoint", "storage.s3.bucket", "storage.s3: access_key and secret_key", "users.avatar.thumb_size"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected a problem for %s, got %v", want, err)
+		}
+	}
+}
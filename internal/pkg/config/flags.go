@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	configFlag  = "config"
+	profileFlag = "profile"
+)
+
+// field is one leaf of Config, addressed by its dotted config key.
+type field struct {
+	key    string
+	typ    reflect.Type
+	secret bool
+}
+
+// fields walks Config and returns every leaf in declaration order. Flags,
+// unknown-key detection and config print are all derived from it, so a new
+// field only needs its struct tag and a default.
+func fields(t reflect.Type, prefix string) []field {
+	var out []field
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+		if f.Type.Kind() == reflect.Struct {
+			out = append(out, fields(f.Type, key+".")...)
+			continue
+		}
+		out = append(out, field{key: key, typ: f.Type, secret: f.Tag.Get("secret") == "true"})
+	}
+
+	return out
+}
+
+// FlagName maps a config key to its command line flag, e.g.
+// http.read_timeout becomes --http-read-timeout.
+func FlagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// RegisterFlags adds --config, --profile and one flag per config key to fs. Flag
+// defaults are only shown in help; unset flags never override the file or
+// environment.
+func RegisterFlags(fs *pflag.FlagSet) {
+	fs.String(configFlag, "", "config file (default: $"+ConfigEnv+", else config.yaml in "+strings.Join(SearchPaths, ", ")+")")
+	fs.String(profileFlag, "", "profile layered over the config file, e.g. dev or prod (default: $"+ProfileEnv+")")
+
+	defaults := viper.New()
+	setDefaults(defaults)
+
+	for _, f := range fields(reflect.TypeOf(Config{}), "") {
+		if k := f.typ.Kind(); k == reflect.Map || k == reflect.Slice {
+			continue
+		}
+
+		name := FlagName(f.key)
+		usage := "sets " + f.key
+
+		switch f.typ {
+		case reflect.TypeOf(time.Duration(0)):
+			fs.Duration(name, defaults.GetDuration(f.key), usage)
+			continue
+		}
+
+		switch f.typ.Kind() {
+		case reflect.String:
+			def := defaults.GetString(f.key)
+			if f.secret {
+				def = ""
+			}
+			fs.String(name, def, usage)
+		case reflect.Int:
+			fs.Int(name, defaults.GetInt(f.key), usage)
+		case reflect.Int64:
+			fs.Int64(name, defaults.GetInt64(f.key), usage)
+		case reflect.Bool:
+			fs.Bool(name, defaults.GetBool(f.key), usage)
+		case reflect.Float64:
+			fs.Float64(name, defaults.GetFloat64(f.key), usage)
+		default:
+			panic(fmt.Sprintf("config: no flag type for %s (%s)", f.key, f.typ))
+		}
+	}
+}
+
+func bindFlags(v *viper.Viper, fs *pflag.FlagSet) error {
+	for _, f := range fields(reflect.TypeOf(Config{}), "") {
+		flag := fs.Lookup(FlagName(f.key))
+		if flag == nil {
+			continue
+		}
+		if err := v.BindPFlag(f.key, flag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestLoad_Precedence(t *testing.T) {
+	dir := chdirWithTemplates(t)
+
+	file := filepath.Join(dir, "custom.yaml")
+	yaml := `
+server:
+  host: "file-host"
+  port: 7000
+database:
+  dsn: "root:root@tcp(localhost:3306)/myapp"
+log:
+  level: "debug"
+`
+	if err := os.WriteFile(file, []byte(yaml), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	t.Setenv(ConfigEnv, file)
+	t.Setenv("SERVER_PORT", "7001")
+	t.Setenv("SERVER_HOST", "env-host")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"--server-port", "7002"}); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+
+	cfg, err := Load(WithFlags(fs))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if cfg.Server.Port != 7002 {
+		t.Fatalf("expected flag to win with port 7002, got %d", cfg.Server.Port)
+	}
+	if cfg.Server.Host != "env-host" {
+		t.Fatalf("expected env to beat file, got %q", cfg.Server.Host)
+	}
+	if cfg.Log.Level != "debug" {
+		t.Fatalf("expected file value debug, got %q", cfg.Log.Level)
+	}
+	if cfg.Log.Format != "text" {
+		t.Fatalf("expected default format text, got %q", cfg.Log.Format)
+	}
+}
+
+func TestLoad_ExplicitFileMissing(t *testing.T) {
+	chdirWithTemplates(t)
+
+	if _, err := Load(WithFile("does-not-exist.yaml")); err == nil {
+		t.Fatalf("expected error for missing explicit config file")
+	}
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	chdirWithTemplates(t)
+	t.Setenv("DATABASE_DSN", "root:hunter2@tcp(localhost:3306)/myapp")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Print(&buf, cfg); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "hunter2") {
+		t.Fatalf("expected password to be redacted, got:\n%s", out)
+	}
+	if !strings.Contains(out, "root:******@tcp(localhost:3306)/myapp") {
+		t.Fatalf("expected redacted DSN, got:\n%s", out)
+	}
+	if !strings.Contains(out, "read_timeout: 15s") {
+		t.Fatalf("expected durations printed as strings, got:\n%s", out)
+	}
+}
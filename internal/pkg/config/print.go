@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"go.yaml.in/yaml/v3"
+)
+
+const redacted = "******"
+
+// Print writes the effective configuration as YAML, in the same layout as
+// config.yaml, with secret values masked.
+func Print(w io.Writer, c *Config) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := map[string]*yaml.Node{"": root}
+
+	v := reflect.ValueOf(*c)
+	for _, f := range fields(v.Type(), "") {
+		parent := root
+		parts := strings.Split(f.key, ".")
+		for i := range parts[:len(parts)-1] {
+			path := strings.Join(parts[:i+1], ".")
+			section, ok := sections[path]
+			if !ok {
+				section = &yaml.Node{Kind: yaml.MappingNode}
+				parent.Content = append(parent.Content, scalar(parts[i]), section)
+				sections[path] = section
+			}
+			parent = section
+		}
+
+		fv := fieldByPath(v, parts)
+		switch fv.Kind() {
+		case reflect.Map:
+			parent.Content = append(parent.Content, scalar(parts[len(parts)-1]), mapNode(fv))
+			continue
+		case reflect.Slice:
+			parent.Content = append(parent.Content, scalar(parts[len(parts)-1]), sliceNode(fv))
+			continue
+		}
+
+		value := formatValue(fv)
+		if f.secret {
+			value = redact(value)
+		}
+		parent.Content = append(parent.Content, scalar(parts[len(parts)-1]), scalar(value))
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func fieldByPath(v reflect.Value, path []string) reflect.Value {
+	for _, name := range path {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get("mapstructure") == name {
+				v = v.Field(i)
+				break
+			}
+		}
+	}
+	return v
+}
+
+func formatValue(v reflect.Value) string {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func mapNode(v reflect.Value) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		node.Content = append(node.Content, scalar(k), scalar(formatValue(v.MapIndex(reflect.ValueOf(k)))))
+	}
+	return node
+}
+
+// sliceNode prints a list of structs, such as users.attributes, as a
+// sequence of mappings keyed like the config file.
+func sliceNode(v reflect.Value) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.SequenceNode}
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		if item.Kind() != reflect.Struct {
+			node.Content = append(node.Content, scalar(formatValue(item)))
+			continue
+		}
+
+		m := &yaml.Node{Kind: yaml.MappingNode}
+		for j := 0; j < item.NumField(); j++ {
+			name := item.Type().Field(j).Tag.Get("mapstructure")
+			if name == "" || name == "-" {
+				continue
+			}
+			m.Content = append(m.Content, scalar(name), scalar(formatValue(item.Field(j))))
+		}
+		node.Content = append(node.Content, m)
+	}
+	return node
+}
+
+// redact masks a secret. DSNs keep everything but the password so the
+// printed value still shows which server and database are used.
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	if dsn, err := mysql.ParseDSN(value); err == nil && dsn.Addr != "" {
+		if dsn.Passwd != "" {
+			dsn.Passwd = redacted
+		}
+		return dsn.FormatDSN()
+	}
+	return redacted
+}
+
+func scalar(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: s}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// resolve fills in DSN from dsn_file or the structured fields. Problems are
+// reported against the key that caused them.
+func (d *DatabaseConfig) resolve() []Problem {
+	var problems []Problem
+	add := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if d.DSNFile != "" {
+		if d.DSN != "" {
+			add("database.dsn_file", "cannot be combined with database.dsn")
+		} else if dsn, err := readSecret(d.DSNFile); err != nil {
+			add("database.dsn_file", "%v", err)
+		} else {
+			d.DSN = dsn
+		}
+	}
+
+	if d.PasswordFile != "" {
+		if d.Password != "" {
+			add("database.password_file", "cannot be combined with database.password")
+		} else if password, err := readSecret(d.PasswordFile); err != nil {
+			add("database.password_file", "%v", err)
+		} else {
+			d.Password = password
+		}
+	}
+
+	if d.Host == "" {
+		return problems
+	}
+	if d.DSN != "" {
+		add("database.host", "cannot be combined with database.dsn or database.dsn_file")
+		return problems
+	}
+	if d.Port < 1 || d.Port > 65535 {
+		add("database.port", "must be between 1 and 65535, got %d", d.Port)
+	}
+	if d.User == "" {
+		add("database.user", "is required with database.host")
+	}
+	if d.DBName == "" {
+		add("database.dbname", "is required with database.host")
+	}
+	if _, err := url.ParseQuery(d.Params); err != nil {
+		add("database.params", "%v", err)
+	}
+	if len(problems) == 0 {
+		d.DSN = d.assemble()
+	}
+	return problems
+}
+
+// assemble builds a DSN from the structured fields. Params are appended as
+// given so the driver parses them exactly like those in a hand written DSN.
+func (d *DatabaseConfig) assemble() string {
+	c := mysql.NewConfig()
+	c.User = d.User
+	c.Passwd = d.Password
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+	c.DBName = d.DBName
+
+	dsn := c.FormatDSN()
+	if d.Params != "" {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + d.Params
+	}
+	return dsn
+}
+
+// resolve reads Secret from secret_file.
+func (s *SessionConfig) resolve() []Problem {
+	if s.SecretFile == "" {
+		return nil
+	}
+	if s.Secret != "" {
+		return []Problem{{Key: "session.secret_file", Message: "cannot be combined with session.secret"}}
+	}
+
+	secret, err := readSecret(s.SecretFile)
+	if err != nil {
+		return []Problem{{Key: "session.secret_file", Message: err.Error()}}
+	}
+	s.Secret = secret
+	return nil
+}
+
+// resolve reads SecretKey from secret_key_file.
+func (s *S3Config) resolve() []Problem {
+	if s.SecretKeyFile == "" {
+		return nil
+	}
+	if s.SecretKey != "" {
+		return []Problem{{Key: "storage.s3.secret_key_file", Message: "cannot be combined with storage.s3.secret_key"}}
+	}
+
+	secret, err := readSecret(s.SecretKeyFile)
+	if err != nil {
+		return []Problem{{Key: "storage.s3.secret_key_file", Message: err.Error()}}
+	}
+	s.SecretKey = secret
+	return nil
+}
+
+// readSecret reads a value mounted as a file. The trailing newline most
+// tools write is not part of the secret.
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimRight(string(b), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return value, nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoad_DSNFromFile(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	os.Unsetenv("DATABASE_DSN")
+
+	secret := filepath.Join(dir, "dsn")
+	writeFile(t, secret, "app:s3cret@tcp(db:3306)/myapp\n")
+	t.Setenv("DATABASE_DSN_FILE", secret)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if cfg.Database.DSN != "app:s3cret@tcp(db:3306)/myapp" {
+		t.Fatalf("expected DSN from file without newline, got %q", cfg.Database.DSN)
+	}
+}
+
+func TestLoad_AssemblesDSN(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	os.Unsetenv("DATABASE_DSN")
+
+	writeFile(t, filepath.Join(dir, "password"), "p@ss:word\n")
+	writeFile(t, filepath.Join(dir, "config.yaml"), `
+database:
+  host: "db.internal"
+  user: "app"
+  password_file: "password"
+  dbname: "myapp"
+`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	want := "app:p@ss:word@tcp(db.internal:3306)/myapp?parseTime=true"
+	if cfg.Database.DSN != want {
+		t.Fatalf("expected DSN %q, got %q", want, cfg.Database.DSN)
+	}
+	if cfg.Database.Password != "p@ss:word" {
+		t.Fatalf("expected password from file, got %q", cfg.Database.Password)
+	}
+}
+
+func TestLoad_ConflictingSecretSources(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	t.Setenv("DATABASE_DSN", "root:root@tcp(localhost:3306)/myapp")
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), `
+database:
+  dsn_file: "missing"
+  host: "db.internal"
+  password: "a"
+  password_file: "also-missing"
+`)
+
+	_, err := Load()
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+
+	keys := map[string]bool{}
+	for _, p := range verr.Problems {
+		keys[p.Key] = true
+	}
+	for _, want := range []string{"database.dsn_file", "database.password_file", "database.host"} {
+		if !keys[want] {
+			t.Fatalf("expected a problem for %s, got %v", want, err)
+		}
+	}
+}
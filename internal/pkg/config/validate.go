@@ -0,0 +1,273 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Problem is a single validation failure, reported against the dotted key
+// as it appears in config.yaml.
+type Problem struct {
+	Key     string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+// ValidationError lists every problem found rather than stopping at the
+// first one, so a broken config can be fixed in one pass.
+type ValidationError struct {
+	File     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+
+	b.WriteString("invalid configuration")
+	if e.File != "" {
+		b.WriteString(" in " + e.File)
+	}
+	for _, p := range e.Problems {
+		b.WriteString("\n  - " + p.String())
+	}
+	return b.String()
+}
+
+// Validate checks the whole configuration, including that referenced files
+// exist. Load already calls it; it is exported for configs built by hand.
+func (c *Config) Validate() error {
+	if problems := c.validate(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (c *Config) validate() []Problem {
+	var problems []Problem
+	add := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if c.Server.ShutdownDrain < 0 {
+		add("server.shutdown_drain", "must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= c.Server.ShutdownDrain {
+		add("server.shutdown_timeout", "must be longer than server.shutdown_drain (%s)", c.Server.ShutdownDrain)
+	}
+
+	tls := c.Server.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		add("server.tls", "cert_file and key_file must be set together")
+	}
+	checkFile(add, "server.tls.cert_file", tls.CertFile)
+	checkFile(add, "server.tls.key_file", tls.KeyFile)
+	checkFile(add, "server.tls.client_ca_file", tls.ClientCAFile)
+	if !oneOf(tls.ClientAuth, "", "none", "request", "require", "verify_if_given", "require_and_verify") {
+		add("server.tls.client_auth", "unknown value %q", tls.ClientAuth)
+	}
+	if tls.RedirectPort != 0 {
+		if !tls.Enabled() {
+			add("server.tls.redirect_port", "requires cert_file and key_file")
+		}
+		if tls.RedirectPort < 1 || tls.RedirectPort > 65535 {
+			add("server.tls.redirect_port", "must be between 1 and 65535, got %d", tls.RedirectPort)
+		}
+		if tls.RedirectPort == c.Server.Port {
+			add("server.tls.redirect_port", "must differ from server.port")
+		}
+	}
+
+	db := c.Database
+	if db.DSN == "" {
+		// A set dsn_file or host that failed to resolve is already reported
+		// against that key.
+		if db.DSNFile == "" && db.Host == "" {
+			add("database.dsn", "is empty (set database.dsn, database.dsn_file or database.host)")
+		}
+	} else if _, err := mysql.ParseDSN(db.DSN); err != nil {
+		add("database.dsn", "cannot be parsed: %v", err)
+	}
+
+	// An empty templates.path means only the embedded templates are used.
+	if path := c.Templates.Path; strings.ContainsAny(path, "*?[") {
+		if matches, err := filepath.Glob(path); err != nil {
+			add("templates.path", "invalid pattern: %v", err)
+		} else if len(matches) == 0 {
+			add("templates.path", "%q matches no files", path)
+		}
+	} else if path != "" {
+		if fi, err := os.Stat(path); err != nil {
+			add("templates.path", "%v", err)
+		} else if !fi.IsDir() {
+			add("templates.path", "%s is not a directory", path)
+		}
+	}
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"http.read_timeout", c.HTTP.ReadTimeout},
+		{"http.read_header_timeout", c.HTTP.ReadHeaderTimeout},
+		{"http.write_timeout", c.HTTP.WriteTimeout},
+		{"http.idle_timeout", c.HTTP.IdleTimeout},
+		{"http.headers.hsts_max_age", c.HTTP.Headers.HSTSMaxAge},
+	} {
+		if d.value < 0 {
+			add(d.key, "must not be negative")
+		}
+	}
+	if c.HTTP.MaxHeaderBytes <= 0 {
+		add("http.max_header_bytes", "must be positive")
+	}
+	if c.HTTP.MaxBodyBytes <= 0 {
+		add("http.max_body_bytes", "must be positive")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		add("log.level", "unknown level %q (expected debug, info, warn or error)", c.Log.Level)
+	}
+	if !oneOf(strings.ToLower(c.Log.Format), "text", "json") {
+		add("log.format", "unknown format %q (expected text or json)", c.Log.Format)
+	}
+
+	if c.Metrics.Enabled && !strings.HasPrefix(c.Metrics.Path, "/") {
+		add("metrics.path", "must start with /")
+	}
+
+	if !oneOf(strings.ToLower(c.Tracing.Exporter), "none", "stdout", "otlp") {
+		add("tracing.exporter", "unknown exporter %q (expected otlp, stdout or none)", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	if c.Health.Timeout <= 0 {
+		add("health.timeout", "must be positive")
+	}
+
+	if c.Limits.MaxPageSize <= 0 {
+		add("limits.max_page_size", "must be positive")
+	}
+	if c.Limits.DefaultPageSize <= 0 || c.Limits.DefaultPageSize > c.Limits.MaxPageSize {
+		add("limits.default_page_size", "must be between 1 and limits.max_page_size")
+	}
+
+	if n := len(c.Session.Secret); n > 0 && n < 32 {
+		add("session.secret", "must be at least 32 bytes, got %d", n)
+	}
+
+	seen := make(map[string]bool)
+	for i, d := range c.Users.Attributes {
+		key := fmt.Sprintf("users.attributes[%d]", i)
+		if err := d.Check(); err != nil {
+			add(key, "%v", err)
+		}
+		if seen[d.Name] {
+			add(key, "duplicate name %q", d.Name)
+		}
+		seen[d.Name] = true
+	}
+
+	avatar := c.Users.Avatar
+	if avatar.MaxBytes <= 0 {
+		add("users.avatar.max_bytes", "must be positive")
+	}
+	if avatar.MaxPixels <= 0 {
+		add("users.avatar.max_pixels", "must be positive")
+	}
+	if avatar.Size < 16 || avatar.Size > 2048 {
+		add("users.avatar.size", "must be between 16 and 2048, got %d", avatar.Size)
+	}
+	if avatar.ThumbSize < 16 || avatar.ThumbSize > avatar.Size {
+		add("users.avatar.thumb_size", "must be between 16 and users.avatar.size, got %d", avatar.ThumbSize)
+	}
+
+	switch st := c.Storage; strings.ToLower(st.Driver) {
+	case "local":
+		if st.Path == "" {
+			add("storage.path", "is required with the local driver")
+		}
+	case "s3":
+		if u, err := url.Parse(st.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("storage.s3.endpoint", "must be an http or https URL, got %q", st.S3.Endpoint)
+		}
+		if st.S3.Region == "" {
+			add("storage.s3.region", "is required with the s3 driver")
+		}
+		if st.S3.Bucket == "" {
+			add("storage.s3.bucket", "is required with the s3 driver")
+		}
+		if (st.S3.AccessKey == "") != (st.S3.SecretKey == "") {
+			add("storage.s3", "access_key and secret_key must be set together")
+		}
+	default:
+		add("storage.driver", "unknown driver %q (expected local or s3)", st.Driver)
+	}
+
+	return problems
+}
+
+func checkFile(add func(key, format string, args ...any), key, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		add(key, "%v", err)
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func oneOf(s string, values ...string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownKeys reports keys that don't map to a Config field, which is
+// almost always a typo that would otherwise silently fall back to the
+// default.
+func unknownKeys(keys []string) []Problem {
+	known := make(map[string]bool)
+	var maps []string
+	for _, f := range fields(reflect.TypeOf(Config{}), "") {
+		known[f.key] = true
+		if f.typ.Kind() == reflect.Map {
+			maps = append(maps, f.key+".")
+		}
+	}
+
+	var problems []Problem
+	for _, key := range keys {
+		if !known[key] && !hasAnyPrefix(key, maps) {
+			problems = append(problems, Problem{Key: key, Message: "unknown key"})
+		}
+	}
+	return problems
+}
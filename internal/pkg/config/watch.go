@@ -0,0 +1,272 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadable lists the keys (or key prefixes ending in ".") whose new
+// values are applied to a running server. Any other change only takes
+// effect after a restart.
+var reloadable = []string{
+	"log.level",
+	"http.max_body_bytes",
+	"limits.",
+	"features",
+	"templates.",
+	"users.attributes",
+	"users.avatar.",
+}
+
+func IsReloadable(key string) bool {
+	for _, r := range reloadable {
+		if key == r || (strings.HasSuffix(r, ".") && strings.HasPrefix(key, r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes returns the keys whose values differ between old and new.
+func Changes(old, new *Config) []string {
+	var changed []string
+
+	ov, nv := reflect.ValueOf(*old), reflect.ValueOf(*new)
+	for _, f := range fields(ov.Type(), "") {
+		path := strings.Split(f.key, ".")
+		if !reflect.DeepEqual(fieldByPath(ov, path).Interface(), fieldByPath(nv, path).Interface()) {
+			changed = append(changed, f.key)
+		}
+	}
+	return changed
+}
+
+// Update is published to subscribers after the config file changed and
+// the new contents loaded and validated.
+type Update struct {
+	// New is the config now in effect: the loaded one, with the keys in
+	// RestartRequired still holding their Old values.
+	Old, New *Config
+	// Reloaded are changed keys that subscribers apply live.
+	Reloaded []string
+	// RestartRequired are changed keys that only take effect on restart.
+	RestartRequired []string
+}
+
+// Watcher holds the current configuration and reloads it whenever the
+// config file changes. An invalid file is reported through OnError and the
+// previous configuration stays in effect.
+type Watcher struct {
+	opts  *loadOptions
+	files []string
+
+	mu      sync.RWMutex
+	current *Config
+	subs    []func(Update) error
+	onError func(error)
+
+	fsw  *fsnotify.Watcher
+	done chan struct{}
+}
+
+// Watch loads the configuration like Load and starts watching the files it
+// came from. Without a config file there is nothing to watch and the
+// Watcher simply holds the loaded values.
+func Watch(opts ...Option) (*Watcher, error) {
+	o := &loadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cfg, files, err := load(o)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		opts:    o,
+		files:   files,
+		current: cfg,
+		onError: func(error) {},
+		done:    make(chan struct{}),
+	}
+
+	if len(files) == 0 {
+		return w, nil
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Watch the directories: editors and secret mounts replace the file
+	// rather than writing it in place.
+	for _, file := range files {
+		if err := fsw.Add(filepath.Dir(file)); err != nil {
+			_ = fsw.Close()
+			return nil, err
+		}
+	}
+	w.fsw = fsw
+
+	go w.loop()
+
+	return w, nil
+}
+
+// Config returns the configuration in effect. Changes that need a restart
+// are not part of it until then.
+func (w *Watcher) Config() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Files returns the config files in use, base file first and then the
+// profile, or nothing when running on defaults, environment and flags only.
+func (w *Watcher) Files() []string {
+	return w.files
+}
+
+// Subscribe registers fn to be called, in registration order, after each
+// successful reload that changed at least one value. When fn returns an
+// error the later subscribers are skipped, the error is reported through
+// OnError and the previous configuration stays current, so the next change
+// to the files delivers the same keys again.
+func (w *Watcher) Subscribe(fn func(Update) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs = append(w.subs, fn)
+}
+
+func (w *Watcher) OnError(fn func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = fn
+}
+
+func (w *Watcher) Close() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+		close(w.done)
+	}
+	if w.fsw == nil {
+		return nil
+	}
+	return w.fsw.Close()
+}
+
+func (w *Watcher) loop() {
+	// Saving a file often produces several events in quick succession;
+	// wait for them to settle before reloading.
+	const settle = 100 * time.Millisecond
+
+	var timer *time.Timer
+	reload := make(chan struct{}, 1)
+	targets := make(map[string]bool)
+	for _, file := range w.files {
+		targets[filepath.Clean(file)] = true
+	}
+
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			// Kubernetes swaps mounted files via a ..data symlink, which
+			// never produces an event for the file name itself.
+			if !targets[filepath.Clean(ev.Name)] && !strings.HasPrefix(filepath.Base(ev.Name), "..") {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(settle, func() {
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
+			})
+		case <-reload:
+			w.reload()
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.reportError(err)
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+func (w *Watcher) reload() {
+	cfg, _, err := load(w.opts)
+	if err != nil {
+		w.reportError(err)
+		return
+	}
+
+	w.mu.Lock()
+	old := w.current
+	changed := Changes(old, cfg)
+	if len(changed) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	u := Update{Old: old, New: cfg}
+	for _, key := range changed {
+		if IsReloadable(key) {
+			u.Reloaded = append(u.Reloaded, key)
+		} else {
+			u.RestartRequired = append(u.RestartRequired, key)
+		}
+	}
+	keepValues(cfg, old, u.RestartRequired)
+	w.current = cfg
+	subs := append([]func(Update) error{}, w.subs...)
+	w.mu.Unlock()
+
+	for _, fn := range subs {
+		if err := fn(u); err != nil {
+			w.mu.Lock()
+			w.current = old
+			w.mu.Unlock()
+			w.reportError(err)
+			return
+		}
+	}
+}
+
+// keepValues copies the values of keys from src to dst.
+func keepValues(dst, src *Config, keys []string) {
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for _, key := range keys {
+		path := strings.Split(key, ".")
+		fieldByPath(dv, path).Set(fieldByPath(sv, path))
+	}
+}
+
+func (w *Watcher) reportError(err error) {
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		w.reload()
+		return
+	}
+
+	w.mu.RLock()
+	fn := w.onError
+	w.mu.RUnlock()
+	fn(err)
+}
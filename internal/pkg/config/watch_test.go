@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const watchBase = `
+database:
+  dsn: "root:root@tcp(localhost:3306)/myapp"
+log:
+  level: "info"
+`
+
+func TestWatch_PublishesReloadableAndRestartChanges(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	file := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(file, []byte(watchBase), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	w, err := Watch(WithFile(file))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	defer w.Close()
+
+	updates := make(chan Update, 1)
+	w.Subscribe(func(u Update) error {
+		updates <- u
+		return nil
+	})
+
+	changed := watchBase + `
+server:
+  port: 9999
+limits:
+  max_page_size: 50
+features:
+  live_search: false
+`
+	changed = strings.Replace(changed, `level: "info"`, `level: "debug"`, 1)
+	if err := os.WriteFile(file, []byte(changed), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	select {
+	case u := <-updates:
+		for _, key := range []string{"log.level", "limits.max_page_size", "features"} {
+			if !slices.Contains(u.Reloaded, key) {
+				t.Fatalf("expected %s to be reloaded, got %v", key, u.Reloaded)
+			}
+		}
+		if !slices.Equal(u.RestartRequired, []string{"server.port"}) {
+			t.Fatalf("expected server.port to require restart, got %v", u.RestartRequired)
+		}
+		if w.Config().Log.Level != "debug" || w.Config().Features["live_search"] {
+			t.Fatalf("expected current config to be updated, got %+v", w.Config())
+		}
+		if w.Config().Server.Port != u.Old.Server.Port || u.New.Server.Port != u.Old.Server.Port {
+			t.Fatalf("expected server.port to keep %d until a restart, got %d", u.Old.Server.Port, w.Config().Server.Port)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no update published")
+	}
+}
+
+func TestWatch_InvalidFileKeepsPreviousConfig(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	file := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(file, []byte(watchBase), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	w, err := Watch(WithFile(file))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	defer w.Close()
+
+	errs := make(chan error, 1)
+	w.OnError(func(err error) { errs <- err })
+	w.Subscribe(func(u Update) error {
+		t.Errorf("unexpected update %+v", u)
+		return nil
+	})
+
+	typo := strings.Replace(watchBase, `level: "info"`, `levle: "debug"`, 1)
+	if err := os.WriteFile(file, []byte(typo), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	select {
+	case <-errs:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("expected reload error")
+	}
+
+	if w.Config().Log.Level != "info" {
+		t.Fatalf("expected previous config to stay, got %q", w.Config().Log.Level)
+	}
+}
+
+func TestWatch_FailedSubscriberKeepsPreviousConfig(t *testing.T) {
+	dir := chdirWithTemplates(t)
+	file := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(file, []byte(watchBase), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	w, err := Watch(WithFile(file))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	defer w.Close()
+
+	errs := make(chan error, 1)
+	w.OnError(func(err error) { errs <- err })
+	// The first update fails to apply and the second succeeds.
+	fail := errors.New("apply failed")
+	results := make(chan error, 2)
+	results <- fail
+	results <- nil
+	updates := make(chan Update, 2)
+	w.Subscribe(func(u Update) error {
+		updates <- u
+		return <-results
+	})
+
+	changed := strings.Replace(watchBase, `level: "info"`, `level: "debug"`, 1)
+	if err := os.WriteFile(file, []byte(changed), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	<-updates
+	select {
+	case err := <-errs:
+		if !errors.Is(err, fail) {
+			t.Fatalf("expected the subscriber's error, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("expected the failure to be reported")
+	}
+	if w.Config().Log.Level != "info" {
+		t.Fatalf("expected previous config to stay, got %q", w.Config().Log.Level)
+	}
+
+	// Saving the file again delivers the same change.
+	if err := os.WriteFile(file, []byte(changed), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	select {
+	case u := <-updates:
+		if !slices.Contains(u.Reloaded, "log.level") {
+			t.Fatalf("expected log.level to be retried, got %v", u.Reloaded)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no update published")
+	}
+	if w.Config().Log.Level != "debug" {
+		t.Fatalf("expected the new config to be current, got %q", w.Config().Log.Level)
+	}
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditCreated = "created"
+	AuditUpdated = "updated"
+	AuditDeleted = "deleted"
+)
+
+// FieldChange is one field touched by an audited action. Old is empty for
+// created users and New for deleted ones.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// AuditEntry records a change to a user. Entries outlive the user, so the
+// history of a deleted user can still be looked up.
+type AuditEntry struct {
+	ID      int64         `json:"id"`
+	UserID  int64         `json:"user_id"`
+	Action  string        `json:"action"`
+	Changes []FieldChange `json:"changes"`
+	At      time.Time     `json:"at"`
+}
+
+// auditFields names the values returned by auditValues.
+var auditFields = []string{"name", "email", "birth_date", "birth_date_approximate", "phone", "department", "title", "locale", "attributes", "tags", "manager", "avatar"}
+
+func auditValues(u *User) []string {
+	var attrs string
+	if len(u.Attributes) > 0 {
+		// Keys are marshalled in sorted order, so equal maps compare equal.
+		b, _ := json.Marshal(u.Attributes)
+		attrs = string(b)
+	}
+	var birthDate, approximate string
+	if !u.BirthDate.IsZero() {
+		birthDate = dateValue(u.BirthDate)
+	}
+	if u.BirthDateApproximate {
+		approximate = "true"
+	}
+	var manager string
+	if u.ManagerID != nil {
+		manager = strconv.FormatInt(*u.ManagerID, 10)
+	}
+	return []string{u.Name, u.Email, birthDate, approximate, u.Phone, u.Department, u.Title, u.Locale, attrs, FormatTags(u.Tags), manager, u.Avatar}
+}
+
+// diffUsers lists the fields that differ between before and after; either
+// may be nil for a created or deleted user.
+func diffUsers(before, after *User) []FieldChange {
+	var old, cur []string
+	if before != nil {
+		old = auditValues(before)
+	}
+	if after != nil {
+		cur = auditValues(after)
+	}
+
+	changes := make([]FieldChange, 0, len(auditFields))
+	for i, field := range auditFields {
+		c := FieldChange{Field: field}
+		if old != nil {
+			c.Old = old[i]
+		}
+		if cur != nil {
+			c.New = cur[i]
+		}
+		if c.Old != c.New {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+const insertAudit = `INSERT INTO user_audit (user_id, action, changes, created_at) VALUES (?, ?, ?, ?)`
+
+// recordAudit logs a change made at the given time, which is also the
+// user's updated_at, so the two agree.
+func recordAudit(ctx context.Context, ex execer, userID int64, action string, changes []FieldChange, at time.Time) error {
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	_, err = ex.ExecContext(ctx, insertAudit, userID, action, string(b), at)
+	return err
+}
+
+// GetUserAudit returns the audit log of a user, oldest first.
+func (db *DB) GetUserAudit(ctx context.Context, userID int64) (_ []AuditEntry, err error) {
+	ctx, done := db.observe(ctx, userRepository, "GetUserAudit")
+	defer func() { done(err) }()
+
+	rows, err := db.Conn.QueryContext(
+		ctx,
+		`SELECT id, user_id, action, changes, created_at FROM user_audit WHERE user_id = ? ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		var changes []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Action, &changes, &e.At); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &e.Changes); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SetUserAvatar records version as the avatar of the user with the given
+// id, or removes the avatar when version is empty. It returns the version
+// it replaced, whose files the caller can then delete.
+func (db *DB) SetUserAvatar(ctx context.Context, id int64, version string) (old string, err error) {
+	ctx, done := db.observe(ctx, userRepository, "SetUserAvatar")
+	defer func() { done(err) }()
+
+	err = db.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		old = before.Avatar
+		if old == version {
+			return nil
+		}
+
+		after := *before
+		after.Avatar = version
+		at := now()
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET avatar = ?, updated_at = ? WHERE id = ?`, version, at, id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, id, AuditUpdated, diffUsers(before, &after), at)
+	})
+	return old, err
+}
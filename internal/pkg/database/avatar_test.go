@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSetUserAvatar(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + userColumns + ` FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, nil, "old1", testTime, testTime))
+	expectTags(mock)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET avatar = ?, updated_at = ? WHERE id = ?`)).
+		WithArgs("new2", sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).
+		WithArgs(int64(7), AuditUpdated, `[{"field":"avatar","old":"old1","new":"new2"}]`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	old, err := db.SetUserAvatar(context.Background(), 7, "new2")
+	if err != nil || old != "old1" {
+		t.Fatalf("expected old1 and nil error, got %q %v", old, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSetUserAvatar_NotFound(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + userColumns + ` FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns))
+	mock.ExpectRollback()
+
+	if _, err := db.SetUserAvatar(context.Background(), 7, ""); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ParseDate reads a date such as a birth date in 2006-01-02 format.
+func ParseDate(s string) (time.Time, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected 2006-01-02)", s)
+	}
+	return t, nil
+}
+
+// Today is the current local date, at midnight UTC like stored dates.
+func Today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// dateValue is the DATE column value for t.
+func dateValue(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+// Age is the user's age in whole years today.
+func (u User) Age() int {
+	return u.AgeAt(Today())
+}
+
+// AgeAt is the user's age in whole years on the given date.
+func (u User) AgeAt(date time.Time) int {
+	if u.BirthDate.IsZero() {
+		return 0
+	}
+	y, m, d := date.Date()
+	age := y - u.BirthDate.Year()
+	if m < u.BirthDate.Month() || (m == u.BirthDate.Month() && d < u.BirthDate.Day()) {
+		age--
+	}
+	return age
+}
+
+// ApproximateBirthDate returns a birth date for someone who is age years
+// old today, in the middle of the year-long range it can fall in. Users
+// given one should have BirthDateApproximate set.
+func ApproximateBirthDate(age int) time.Time {
+	return Today().AddDate(-age, -6, 0)
+}
+
+// userJSON is how User is encoded: the birth date as a date and the age it
+// gives today.
+type userJSON struct {
+	plainUser
+	BirthDate string `json:"birth_date"`
+	Age       *int   `json:"age,omitempty"`
+}
+
+// plainUser has the fields of User without its JSON methods.
+type plainUser User
+
+func (u User) MarshalJSON() ([]byte, error) {
+	v := userJSON{plainUser: plainUser(u)}
+	if !u.BirthDate.IsZero() {
+		age := u.Age()
+		v.BirthDate, v.Age = dateValue(u.BirthDate), &age
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON reads users as written by MarshalJSON. Without a birth
+// date, as in exports made before birth dates were stored, an age is
+// turned into an approximate one.
+func (u *User) UnmarshalJSON(b []byte) error {
+	var v userJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*u = User(v.plainUser)
+
+	switch {
+	case v.BirthDate != "":
+		t, err := ParseDate(v.BirthDate)
+		if err != nil {
+			return err
+		}
+		u.BirthDate = t
+	case v.Age != nil && *v.Age > 0:
+		u.BirthDate, u.BirthDateApproximate = ApproximateBirthDate(*v.Age), true
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUser_AgeAt(t *testing.T) {
+	u := User{BirthDate: time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC)}
+	tests := map[string]int{
+		"2024-02-28": 23,
+		"2024-02-29": 24,
+		"2025-02-28": 24,
+		"2025-03-01": 25,
+	}
+	for date, want := range tests {
+		d, _ := ParseDate(date)
+		if got := u.AgeAt(d); got != want {
+			t.Fatalf("%s: expected age %d, got %d", date, want, got)
+		}
+	}
+}
+
+func TestUser_JSON(t *testing.T) {
+	u := User{ID: 1, Name: "Ana", Email: "ana@test.com", BirthDate: birth1990}
+	b, err := json.Marshal(u)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !strings.Contains(string(b), `"birth_date":"1990-06-15"`) || !strings.Contains(string(b), `"age":`) {
+		t.Fatalf("expected birth date and age, got %s", b)
+	}
+
+	var got User
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !got.BirthDate.Equal(birth1990) || got.BirthDateApproximate || got.Name != "Ana" {
+		t.Fatalf("unexpected user %+v", got)
+	}
+
+	// Exports made before birth dates only have an age.
+	if err := json.Unmarshal([]byte(`{"name":"Bo","age":40}`), &got); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if got.Age() != 40 || !got.BirthDateApproximate {
+		t.Fatalf("expected approximate age 40, got %d (%+v)", got.Age(), got)
+	}
+}
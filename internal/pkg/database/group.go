@@ -0,0 +1,334 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrGroupNotFound     = errors.New("group not found")
+	ErrMemberNotFound    = errors.New("user is not a member of the group")
+	ErrGroupNameRequired = errors.New("group name is required")
+	ErrGroupNameTooLong  = errors.New("group name must be at most 255 characters")
+	ErrDescriptionLong   = errors.New("description must be at most 1000 characters")
+	ErrInvalidRole       = errors.New("invalid role")
+)
+
+// Roles a member can have in a group.
+const (
+	RoleMember = "member"
+	RoleLead   = "lead"
+)
+
+// Roles lists the valid roles, leads first.
+var Roles = []string{RoleLead, RoleMember}
+
+func ValidRole(role string) bool {
+	return role == RoleMember || role == RoleLead
+}
+
+// Group is a team users can belong to. MemberCount is filled in by
+// GetGroups and GetGroupByID and ignored when saving.
+type Group struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (g *Group) Validate() error {
+	if strings.TrimSpace(g.Name) == "" {
+		return ErrGroupNameRequired
+	}
+	if utf8.RuneCountInString(g.Name) > 255 {
+		return ErrGroupNameTooLong
+	}
+	if utf8.RuneCountInString(g.Description) > 1000 {
+		return ErrDescriptionLong
+	}
+	return nil
+}
+
+// Member is a user's membership as listed for a group.
+type Member struct {
+	User  User      `json:"user"`
+	Role  string    `json:"role"`
+	Since time.Time `json:"since"`
+}
+
+// Membership is a group as listed for one of its members.
+type Membership struct {
+	Group Group     `json:"group"`
+	Role  string    `json:"role"`
+	Since time.Time `json:"since"`
+}
+
+// GroupQuery selects a page of groups. Limit 0 returns all of them, for
+// short lists such as a filter's options.
+type GroupQuery struct {
+	// Search matches groups whose name contains it.
+	Search string
+	Limit  int
+	Offset int
+}
+
+// groupColumns are the columns scanGroup reads, in order, from user_groups
+// aliased g.
+const groupColumns = `g.id, g.name, g.description, (SELECT COUNT(*) FROM group_members c WHERE c.group_id = g.id), g.created_at, g.updated_at`
+
+func scanGroup(s scanner, g *Group) error {
+	return s.Scan(&g.ID, &g.Name, &g.Description, &g.MemberCount, &g.CreatedAt, &g.UpdatedAt)
+}
+
+func (db *DB) GetGroups(ctx context.Context, q GroupQuery) (_ []Group, err error) {
+	ctx, done := db.observe(ctx, groupRepository, "GetGroups")
+	defer func() { done(err) }()
+
+	query := `SELECT ` + groupColumns + ` FROM user_groups g`
+	var args []any
+	if s := strings.TrimSpace(q.Search); s != "" {
+		query += ` WHERE g.name LIKE ?`
+		args = append(args, "%"+likeEscaper.Replace(s)+"%")
+	}
+	query += ` ORDER BY g.name, g.id`
+	if q.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, q.Limit, q.Offset)
+	}
+
+	rows, err := db.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]Group, 0)
+	for rows.Next() {
+		var g Group
+		if err := scanGroup(rows, &g); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (db *DB) GetGroupByID(ctx context.Context, id int64) (_ *Group, err error) {
+	ctx, done := db.observe(ctx, groupRepository, "GetGroupByID")
+	defer func() { done(err) }()
+
+	g := &Group{}
+	err = scanGroup(db.Conn.QueryRowContext(ctx, `SELECT `+groupColumns+` FROM user_groups g WHERE g.id = ?`, id), g)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (db *DB) CreateGroup(ctx context.Context, g *Group) (err error) {
+	ctx, done := db.observe(ctx, groupRepository, "CreateGroup")
+	defer func() { done(err) }()
+
+	at := now()
+	res, err := db.Conn.ExecContext(
+		ctx,
+		`INSERT INTO user_groups (name, description, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+		g.Name, g.Description, at, at,
+	)
+	if err != nil {
+		return err
+	}
+	if g.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	g.CreatedAt, g.UpdatedAt = at, at
+	return nil
+}
+
+func (db *DB) UpdateGroup(ctx context.Context, g *Group) (err error) {
+	ctx, done := db.observe(ctx, groupRepository, "UpdateGroup")
+	defer func() { done(err) }()
+
+	at := now()
+	res, err := db.Conn.ExecContext(
+		ctx,
+		`UPDATE user_groups SET name = ?, description = ?, updated_at = ? WHERE id = ?`,
+		g.Name, g.Description, at, g.ID,
+	)
+	if err != nil {
+		return err
+	}
+	// updated_at always changes, so no rows means no group.
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrGroupNotFound
+	}
+	g.UpdatedAt = at
+	return nil
+}
+
+// DeleteGroup deletes a group; its memberships go with it.
+func (db *DB) DeleteGroup(ctx context.Context, id int64) (err error) {
+	ctx, done := db.observe(ctx, groupRepository, "DeleteGroup")
+	defer func() { done(err) }()
+
+	res, err := db.Conn.ExecContext(ctx, `DELETE FROM user_groups WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+// GetGroupMembers lists the members of a group, leads first and then by
+// name.
+func (db *DB) GetGroupMembers(ctx context.Context, groupID int64) (_ []Member, err error) {
+	ctx, done := db.observe(ctx, groupRepository, "GetGroupMembers")
+	defer func() { done(err) }()
+
+	rows, err := db.Conn.QueryContext(
+		ctx,
+		`SELECT m.role, m.created_at, `+prefixed("u", userColumns)+`
+		FROM group_members m JOIN users u ON u.id = m.user_id
+		WHERE m.group_id = ?
+		ORDER BY m.role = ? DESC, u.name, u.id`,
+		groupID, RoleLead,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]Member, 0)
+	for rows.Next() {
+		var m Member
+		if err := scanUser(prefixScanner{rows, []any{&m.Role, &m.Since}}, &m.User); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// GetUserGroups lists the groups a user belongs to, by name.
+func (db *DB) GetUserGroups(ctx context.Context, userID int64) (_ []Membership, err error) {
+	ctx, done := db.observe(ctx, groupRepository, "GetUserGroups")
+	defer func() { done(err) }()
+
+	rows, err := db.Conn.QueryContext(
+		ctx,
+		`SELECT m.role, m.created_at, `+groupColumns+`
+		FROM group_members m JOIN user_groups g ON g.id = m.group_id
+		WHERE m.user_id = ?
+		ORDER BY g.name, g.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := make([]Membership, 0)
+	for rows.Next() {
+		var m Membership
+		if err := scanGroup(prefixScanner{rows, []any{&m.Role, &m.Since}}, &m.Group); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+// SetGroupMember adds a user to a group, or changes the role of a member.
+func (db *DB) SetGroupMember(ctx context.Context, groupID, userID int64, role string) (err error) {
+	ctx, done := db.observe(ctx, groupRepository, "SetGroupMember")
+	defer func() { done(err) }()
+
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock both rows so neither is deleted before the insert.
+		var id int64
+		err := tx.QueryRowContext(ctx, `SELECT id FROM user_groups WHERE id = ? FOR UPDATE`, groupID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrGroupNotFound
+		}
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO group_members (group_id, user_id, role, created_at) VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE role = VALUES(role)`,
+			groupID, userID, role, now(),
+		)
+		return err
+	})
+}
+
+func (db *DB) RemoveGroupMember(ctx context.Context, groupID, userID int64) (err error) {
+	ctx, done := db.observe(ctx, groupRepository, "RemoveGroupMember")
+	defer func() { done(err) }()
+
+	res, err := db.Conn.ExecContext(ctx, `DELETE FROM group_members WHERE group_id = ? AND user_id = ?`, groupID, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// prefixed qualifies each of a comma separated list of columns with a
+// table alias.
+func prefixed(alias, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	return strings.Join(cols, ", ")
+}
+
+// prefixScanner scans the leading columns of a row into extra and passes
+// the rest on, so scanUser and scanGroup can read joined rows.
+type prefixScanner struct {
+	s     scanner
+	extra []any
+}
+
+func (p prefixScanner) Scan(dest ...any) error {
+	return p.s.Scan(append(p.extra, dest...)...)
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var groupRowColumns = []string{"id", "name", "description", "member_count", "created_at", "updated_at"}
+
+func TestGetGroups_Search(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT g.id, g.name, g.description, (SELECT COUNT(*) FROM group_members c WHERE c.group_id = g.id), g.created_at, g.updated_at FROM user_groups g WHERE g.name LIKE ? ORDER BY g.name, g.id LIMIT ? OFFSET ?`,
+	)).
+		WithArgs("%ops%", 10, 0).
+		WillReturnRows(sqlmock.NewRows(groupRowColumns).
+			AddRow(int64(1), "Ops", "", 3, testTime, testTime))
+
+	got, err := db.GetGroups(context.Background(), GroupQuery{Search: "ops", Limit: 10})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "Ops" || got[0].MemberCount != 3 {
+		t.Fatalf("unexpected groups %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGetGroupByID_NotFound(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM user_groups g WHERE g.id = ?`)).
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows(groupRowColumns))
+
+	if _, err := db.GetGroupByID(context.Background(), 9); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGroupQueries_SpanName(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	defer otel.SetTracerProvider(prev)
+
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM user_groups g WHERE g.id = ?`)).
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows(groupRowColumns))
+	db.GetGroupByID(context.Background(), 9)
+
+	spans := rec.Ended()
+	if len(spans) != 1 || spans[0].Name() != "GroupRepository.GetGroupByID" {
+		t.Fatalf("expected a GroupRepository span, got %v", spans)
+	}
+}
+
+func TestUpdateGroup_NotFound(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_groups SET name = ?, description = ?, updated_at = ? WHERE id = ?`)).
+		WithArgs("Ops", "", sqlmock.AnyArg(), int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := db.UpdateGroup(context.Background(), &Group{ID: 9, Name: "Ops"}); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGetGroupMembers(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.role, m.created_at, u.id, u.name, u.email, u.birth_date, u.birth_date_approximate, u.phone, u.department, u.title, u.locale, u.attributes, u.manager_id, u.avatar, u.created_at, u.updated_at`)).
+		WithArgs(int64(1), RoleLead).
+		WillReturnRows(sqlmock.NewRows(append([]string{"role", "since"}, userRowColumns...)).
+			AddRow(RoleLead, testTime, int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime))
+
+	got, err := db.GetGroupMembers(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 1 || got[0].Role != RoleLead || got[0].User.ID != 7 || !got[0].Since.Equal(testTime) {
+		t.Fatalf("unexpected members %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSetGroupMember(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM user_groups WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO group_members (group_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`)).
+		WithArgs(int64(1), int64(7), RoleLead, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := db.SetGroupMember(context.Background(), 1, 7, RoleLead); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSetGroupMember_UnknownUserRollsBack(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM user_groups WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	if err := db.SetGroupMember(context.Background(), 1, 7, RoleMember); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := db.SetGroupMember(context.Background(), 1, 7, "owner"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestRemoveGroupMember_NotMember(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM group_members WHERE group_id = ? AND user_id = ?`)).
+		WithArgs(int64(1), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := db.RemoveGroupMember(context.Background(), 1, 7); !errors.Is(err, ErrMemberNotFound) {
+		t.Fatalf("expected ErrMemberNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGetUsers_Group(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM users WHERE id IN (SELECT user_id FROM group_members WHERE group_id = ?) ORDER BY id LIMIT ? OFFSET ?`)).
+		WithArgs(int64(3), 10, 0).
+		WillReturnRows(sqlmock.NewRows(userRowColumns))
+
+	if _, err := db.GetUsers(context.Background(), UserQuery{GroupID: 3, Limit: 10}); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
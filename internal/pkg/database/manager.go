@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrManagerNotFound = errors.New("manager not found")
+	ErrManagerCycle    = errors.New("a user can't report to themselves or to one of their reports")
+)
+
+// Report is a user below another in the management hierarchy. Depth is 1
+// for direct reports, 2 for their reports and so on.
+type Report struct {
+	User  User `json:"user"`
+	Depth int  `json:"depth"`
+}
+
+// sameManager reports whether a and b have the same manager.
+func sameManager(a, b *User) bool {
+	if a.ManagerID == nil || b.ManagerID == nil {
+		return a.ManagerID == b.ManagerID
+	}
+	return *a.ManagerID == *b.ManagerID
+}
+
+// checkManager verifies that the user with id userID, or a user about to
+// be created when it is 0, can report to managerID. The manager's row is
+// locked, so it isn't deleted before the change is saved and two
+// concurrent changes can't make two users report to each other.
+func checkManager(ctx context.Context, tx *sql.Tx, userID int64, managerID *int64) error {
+	if managerID == nil {
+		return nil
+	}
+	if *managerID == userID {
+		return ErrManagerCycle
+	}
+
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? FOR UPDATE`, *managerID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrManagerNotFound
+	}
+	if err != nil || userID == 0 {
+		return err
+	}
+
+	// The new manager must not be below the user. UNION rather than
+	// UNION ALL stops the walk should the data already have a cycle.
+	var n int
+	err = tx.QueryRowContext(
+		ctx,
+		`WITH RECURSIVE chain (id, manager_id) AS (
+			SELECT id, manager_id FROM users WHERE id = ?
+			UNION
+			SELECT u.id, u.manager_id FROM users u JOIN chain c ON u.id = c.manager_id
+		)
+		SELECT COUNT(*) FROM chain WHERE id = ?`,
+		*managerID, userID,
+	).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrManagerCycle
+	}
+	return nil
+}
+
+// GetReports returns the users below the user with the given id, down to
+// depth levels or all of them when depth is 0, ordered by depth and name.
+// An id of 0 returns the whole organization, with the users that have no
+// manager at depth 1.
+func (db *DB) GetReports(ctx context.Context, id int64, depth int) (_ []Report, err error) {
+	ctx, done := db.observe(ctx, userRepository, "GetReports")
+	defer func() { done(err) }()
+
+	anchor, args := `manager_id = ?`, []any{id}
+	if id == 0 {
+		anchor, args = `manager_id IS NULL`, nil
+	}
+	args = append(args, depth, depth)
+
+	rows, err := db.Conn.QueryContext(
+		ctx,
+		`WITH RECURSIVE reports (id, depth) AS (
+			SELECT id, 1 FROM users WHERE `+anchor+`
+			UNION ALL
+			SELECT u.id, r.depth + 1 FROM users u JOIN reports r ON u.manager_id = r.id WHERE ? = 0 OR r.depth < ?
+		)
+		SELECT r.depth, `+prefixed("u", userColumns)+` FROM reports r JOIN users u ON u.id = r.id
+		ORDER BY r.depth, u.name, u.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]Report, 0)
+	for rows.Next() {
+		var r Report
+		if err := scanUser(prefixScanner{s: rows, extra: []any{&r.Depth}}, &r.User); err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+// GetManagementChain returns the managers of the user with the given id,
+// from its direct manager up to the top of the organization.
+func (db *DB) GetManagementChain(ctx context.Context, id int64) (_ []User, err error) {
+	ctx, done := db.observe(ctx, userRepository, "GetManagementChain")
+	defer func() { done(err) }()
+
+	rows, err := db.Conn.QueryContext(
+		ctx,
+		`WITH RECURSIVE chain (id, manager_id, depth) AS (
+			SELECT id, manager_id, 0 FROM users WHERE id = ?
+			UNION ALL
+			SELECT u.id, u.manager_id, c.depth + 1 FROM users u JOIN chain c ON u.id = c.manager_id
+		)
+		SELECT `+prefixed("u", userColumns)+` FROM chain c JOIN users u ON u.id = c.id
+		WHERE c.depth > 0 ORDER BY c.depth`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	managers := make([]User, 0)
+	for rows.Next() {
+		var u User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, err
+		}
+		managers = append(managers, u)
+	}
+	return managers, rows.Err()
+}
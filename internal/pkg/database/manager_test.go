@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func ptr(id int64) *int64 { return &id }
+
+func TestUpdateUser_SetsManager(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + userColumns + ` FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime))
+	expectTags(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(3)))
+	mock.ExpectQuery(regexp.QuoteMeta(`WITH RECURSIVE chain (id, manager_id) AS`)).
+		WithArgs(int64(3), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET`)).
+		WithArgs("Mahir", "mahir@test.com", "2000-01-02", false, "", "", "", "", nil, int64(3), sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).
+		WithArgs(int64(7), AuditUpdated, `[{"field":"manager","new":"3"}]`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	u := &User{ID: 7, Name: "Mahir", Email: "mahir@test.com", BirthDate: birth2000, ManagerID: ptr(3)}
+	if err := db.UpdateUser(context.Background(), u); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestUpdateUser_ManagerCycleRollsBack(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + userColumns + ` FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime))
+	expectTags(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(9)))
+	mock.ExpectQuery(regexp.QuoteMeta(`WITH RECURSIVE chain (id, manager_id) AS`)).
+		WithArgs(int64(9), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	u := &User{ID: 7, Name: "Mahir", Email: "mahir@test.com", BirthDate: birth2000, ManagerID: ptr(9)}
+	if err := db.UpdateUser(context.Background(), u); !errors.Is(err, ErrManagerCycle) {
+		t.Fatalf("expected ErrManagerCycle, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestCreateUser_UnknownManager(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	u := &User{Name: "Mahir", Email: "mahir@test.com", BirthDate: birth2000, ManagerID: ptr(3)}
+	if err := db.CreateUser(context.Background(), u); !errors.Is(err, ErrManagerNotFound) {
+		t.Fatalf("expected ErrManagerNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestUser_ValidateOwnManager(t *testing.T) {
+	u := User{ID: 7, Name: "Mahir", Email: "mahir@test.com", BirthDate: birth2000, ManagerID: ptr(7)}
+	if err := u.Validate(); !errors.Is(err, ErrManagerCycle) {
+		t.Fatalf("expected ErrManagerCycle, got %v", err)
+	}
+}
+
+func TestGetReports(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, 1 FROM users WHERE manager_id = ?`)).
+		WithArgs(int64(3), 0, 0).
+		WillReturnRows(sqlmock.NewRows(append([]string{"depth"}, userRowColumns...)).
+			AddRow(1, int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, int64(3), "", testTime, testTime).
+			AddRow(2, int64(8), "Ana", "ana@test.com", birth1990, false, "", "", "", "", nil, int64(7), "", testTime, testTime))
+
+	got, err := db.GetReports(context.Background(), 3, 0)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 2 || got[0].Depth != 1 || got[0].User.ID != 7 || got[1].Depth != 2 || *got[1].User.ManagerID != 7 {
+		t.Fatalf("unexpected reports %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGetReports_Organization(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, 1 FROM users WHERE manager_id IS NULL`)).
+		WithArgs(2, 2).
+		WillReturnRows(sqlmock.NewRows(append([]string{"depth"}, userRowColumns...)))
+
+	if _, err := db.GetReports(context.Background(), 0, 2); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGetManagementChain(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WITH RECURSIVE chain (id, manager_id, depth) AS`)).
+		WithArgs(int64(8)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, int64(3), "", testTime, testTime).
+			AddRow(int64(3), "Ana", "ana@test.com", birth1990, false, "", "", "", "", nil, nil, "", testTime, testTime))
+
+	got, err := db.GetManagementChain(context.Background(), 8)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 7 || got[1].ID != 3 || got[1].ManagerID != nil {
+		t.Fatalf("unexpected chain %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
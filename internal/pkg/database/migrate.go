@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var ErrPendingMigrations = errors.New("pending migrations")
+
+// Migration is one file in migrations/, named <version>_<name>.sql. Files
+// are applied in version order and may hold several statements separated
+// by semicolons at the end of a line.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	for _, e := range entries {
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.sql", e.Name())
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", e.Name(), err)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m Migration) statements() []string {
+	var stmts []string
+	for _, part := range strings.SplitAfter(m.SQL, ";\n") {
+		stmt := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(part), ";"))
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+func (db *DB) ensureMigrationsTable(ctx context.Context) error {
+	_, err := db.Conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+	return err
+}
+
+func (db *DB) appliedVersions(ctx context.Context) (map[int]bool, error) {
+	applied := make(map[int]bool)
+
+	rows, err := db.Conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		// A missing table just means nothing has been applied yet.
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1146 {
+			return applied, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// PendingMigrations returns the migrations that have not been applied yet.
+func (db *DB) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := db.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]Migration, 0)
+	for _, m := range all {
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies every pending migration and returns the ones it applied.
+// MySQL commits DDL implicitly, so a failing migration may leave earlier
+// statements of the same file applied; keep migrations small.
+func (db *DB) Migrate(ctx context.Context) ([]Migration, error) {
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	pending, err := db.PendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0, len(pending))
+	for _, m := range pending {
+		for _, stmt := range m.statements() {
+			if _, err := db.Conn.ExecContext(ctx, stmt); err != nil {
+				return applied, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+
+		if _, err := db.Conn.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`,
+			m.Version, m.Name,
+		); err != nil {
+			return applied, fmt.Errorf("record migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// CheckMigrations fails with ErrPendingMigrations when the schema is behind
+// the binary.
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	pending, err := db.PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d not applied", ErrPendingMigrations, len(pending))
+	}
+	return nil
+}
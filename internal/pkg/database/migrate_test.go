@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestMigrations_SortedAndParsed(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("expected at least one migration")
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Fatalf("migrations not strictly ordered at %d", migrations[i].Version)
+		}
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_users" {
+		t.Fatalf("unexpected first migration %+v", migrations[0])
+	}
+}
+
+func TestCheckMigrations_MissingTableIsPending(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version FROM schema_migrations`)).
+		WillReturnError(&mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"})
+
+	err := db.CheckMigrations(context.Background())
+	if !errors.Is(err, ErrPendingMigrations) {
+		t.Fatalf("expected ErrPendingMigrations, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestMigrate_AppliesOnlyPending(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	all, err := Migrations()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rows := sqlmock.NewRows([]string{"version"})
+	for _, m := range all {
+		rows.AddRow(m.Version)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version FROM schema_migrations`)).
+		WillReturnRows(rows)
+
+	applied, err := db.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("expected nothing applied, got %d", len(applied))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestMigration_TimestampBackfillUsesCreatedEntries(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	m := migrations[2]
+	if m.Name != "add_user_timestamps" {
+		t.Fatalf("unexpected migration %+v", m)
+	}
+	stmts := m.statements()
+	if len(stmts) != 2 {
+		t.Fatalf("expected the ALTER and the back-fill, got %d statements", len(stmts))
+	}
+	// An edit must never become the creation time.
+	if backfill := stmts[1]; !strings.Contains(backfill, "CASE WHEN action = 'created'") || !strings.Contains(backfill, "WHERE a.created IS NOT NULL") {
+		t.Fatalf("expected created_at to come only from created entries, got %s", backfill)
+	}
+}
+
+// TestMigration_TimestampBackfill_MySQL runs the timestamp back-fill on a
+// real server, in a scratch database. It needs GOAPP_TEST_MYSQL_DSN, the
+// DSN of a user allowed to create databases, and is skipped without it.
+func TestMigration_TimestampBackfill_MySQL(t *testing.T) {
+	dsn := os.Getenv("GOAPP_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("GOAPP_TEST_MYSQL_DSN is not set")
+	}
+	ctx := context.Background()
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("invalid GOAPP_TEST_MYSQL_DSN: %v", err)
+	}
+	cfg.ParseTime = true
+	cfg.DBName = ""
+	admin, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer admin.Close()
+
+	cfg.DBName = fmt.Sprintf("goapp_migrate_test_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+cfg.DBName); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	defer admin.ExecContext(ctx, "DROP DATABASE "+cfg.DBName)
+	conn, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer conn.Close()
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	exec := func(stmts ...string) {
+		t.Helper()
+		for _, stmt := range stmts {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+	}
+	exec(migrations[0].statements()...)
+	exec(migrations[1].statements()...)
+
+	// User 1 was created with the history in place, user 2 predates it
+	// and was only edited since, and user 3 was never touched.
+	exec(
+		`INSERT INTO users (id, name, email, age) VALUES (1, 'A', 'a@test.com', 30), (2, 'B', 'b@test.com', 40), (3, 'C', 'c@test.com', 50)`,
+		`INSERT INTO user_audit (user_id, action, changes, created_at) VALUES
+			(1, 'created', '[]', '2024-01-01 10:00:00'),
+			(1, 'updated', '[]', '2024-02-01 10:00:00'),
+			(2, 'updated', '[]', '2024-03-01 10:00:00'),
+			(2, 'updated', '[]', '2024-04-01 10:00:00')`,
+	)
+	exec(migrations[2].statements()...)
+
+	times := func(id int64) (created, updated time.Time) {
+		t.Helper()
+		if err := conn.QueryRowContext(ctx, `SELECT created_at, updated_at FROM users WHERE id = ?`, id).Scan(&created, &updated); err != nil {
+			t.Fatalf("user %d: %v", id, err)
+		}
+		return created, updated
+	}
+
+	created, updated := times(1)
+	if !created.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) || !updated.Equal(time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("user 1: expected the history's times, got %v %v", created, updated)
+	}
+	defaultCreated, _ := times(3)
+	for _, id := range []int64{2, 3} {
+		created, updated := times(id)
+		if !created.Equal(defaultCreated) || updated.Before(created) {
+			t.Fatalf("user %d: expected the defaults, got %v %v", id, created, updated)
+		}
+	}
+}
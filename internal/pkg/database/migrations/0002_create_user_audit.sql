@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_audit (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    changes JSON NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_user_audit_user (user_id, id)
+);
@@ -0,0 +1,18 @@
+ALTER TABLE users
+    ADD COLUMN created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    ADD COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    ADD INDEX idx_users_created_at (created_at);
+
+-- Only a created entry tells when a user was created. Users from before
+-- the history was recorded start with an edit, so they keep the defaults
+-- rather than an edit time, which also keeps updated_at >= created_at.
+UPDATE users u
+JOIN (
+    SELECT user_id,
+        MIN(CASE WHEN action = 'created' THEN created_at END) AS created,
+        MAX(created_at) AS last_change
+    FROM user_audit
+    GROUP BY user_id
+) a ON a.user_id = u.id
+SET u.created_at = a.created, u.updated_at = a.last_change
+WHERE a.created IS NOT NULL;
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN phone VARCHAR(32) NOT NULL DEFAULT '',
+    ADD COLUMN department VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT '',
+    ADD COLUMN attributes JSON NULL;
@@ -0,0 +1,14 @@
+ALTER TABLE users
+    ADD COLUMN birth_date DATE NULL AFTER email,
+    ADD COLUMN birth_date_approximate BOOLEAN NOT NULL DEFAULT FALSE AFTER birth_date;
+
+-- An age only tells which year-long range the birthday falls in, so users
+-- are placed in the middle of it and flagged until the real date is known.
+UPDATE users
+SET birth_date = DATE_SUB(DATE_SUB(CURRENT_DATE, INTERVAL age YEAR), INTERVAL 6 MONTH),
+    birth_date_approximate = TRUE;
+
+ALTER TABLE users
+    MODIFY COLUMN birth_date DATE NOT NULL,
+    DROP COLUMN age,
+    ADD INDEX idx_users_birth_date (birth_date);
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN manager_id BIGINT NULL,
+    ADD INDEX idx_users_manager_id (manager_id),
+    ADD CONSTRAINT fk_users_manager FOREIGN KEY (manager_id) REFERENCES users (id) ON DELETE SET NULL;
@@ -1,33 +1,100 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"goapp/internal/pkg/metrics"
+	"log/slog"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("goapp/database")
+
 type DB struct {
-	Conn *sql.DB
+	Conn    *sql.DB
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
 }
 
-func New(dsn string) (*DB, error) {
+func New(dsn string, logger *slog.Logger) (*DB, error) {
 	conn, err := sql.Open("mysql", dsn)
-	conn.SetMaxOpenConns(25)
-	conn.SetMaxIdleConns(5)
-	conn.SetConnMaxLifetime(5 * time.Minute)
-
 	if err != nil {
 		return nil, err
 	}
 
+	conn.SetMaxOpenConns(25)
+	conn.SetMaxIdleConns(5)
+	conn.SetConnMaxLifetime(5 * time.Minute)
+
 	if err := conn.Ping(); err != nil {
 		return nil, err
 	}
 
-	return &DB{Conn: conn}, nil
+	return &DB{Conn: conn, Logger: logger}, nil
 }
 
 func (db *DB) Close() error {
 	return db.Conn.Close()
 }
+
+// Repository names, which prefix the span names of their methods.
+const (
+	userRepository  = "UserRepository"
+	groupRepository = "GroupRepository"
+)
+
+// observe wraps every repository method: it starts a span named after repo
+// and method whose context the method must use for its queries, and the
+// returned func, deferred with the method's error, ends the span and
+// reports the call.
+func (db *DB) observe(ctx context.Context, repo, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, repo+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "mysql"),
+			attribute.String("db.operation", method),
+		),
+	)
+
+	return ctx, func(err error) {
+		defer span.End()
+
+		elapsed := time.Since(start)
+		failed := err != nil && !errors.Is(err, ErrUserNotFound)
+
+		if failed {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		db.Metrics.ObserveDBQuery(method, elapsed, failed)
+
+		logger := db.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", method),
+			slog.Duration("duration", elapsed),
+		}
+
+		if failed {
+			logger.LogAttrs(ctx, slog.LevelWarn, "query failed", append(attrs, slog.Any("error", err))...)
+			return
+		}
+		logger.LogAttrs(ctx, slog.LevelDebug, "query", attrs...)
+	}
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return db.Conn.PingContext(ctx)
+}
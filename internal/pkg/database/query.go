@@ -0,0 +1,135 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSort = errors.New("invalid sort field")
+
+// sortColumns maps the fields users can be sorted by to their columns. A
+// leading "-" reverses the order: ages ascend as birth dates descend.
+var sortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"email":      "email",
+	"age":        "-birth_date",
+	"birth_date": "birth_date",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// UserQuery selects a page of users, optionally filtered.
+type UserQuery struct {
+	// Search matches users whose name or email contains it.
+	Search string
+	// CreatedAfter and CreatedBefore, when set, bound created_at; the
+	// first is inclusive and the second exclusive.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// BornAfter and BornBefore, when set, bound birth_date in the same
+	// way.
+	BornAfter  time.Time
+	BornBefore time.Time
+	// GroupID, when set, selects the members of a group.
+	GroupID int64
+	// Tags selects users that have all of them.
+	Tags []string
+	// Sort is a field from sortColumns, prefixed with "-" for descending
+	// order. Users are sorted by ID when it is empty and within equal
+	// values.
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// Validate reports an unknown sort field.
+func (q UserQuery) Validate() error {
+	_, err := q.orderBy()
+	return err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// where returns the WHERE clause, including its leading space, and its
+// arguments; both are empty when nothing is filtered.
+func (q UserQuery) where() (string, []any) {
+	var conds []string
+	var args []any
+
+	if s := strings.TrimSpace(q.Search); s != "" {
+		pattern := "%" + likeEscaper.Replace(s) + "%"
+		conds = append(conds, "(name LIKE ? OR email LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	if !q.CreatedAfter.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, q.CreatedAfter.UTC())
+	}
+	if !q.CreatedBefore.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, q.CreatedBefore.UTC())
+	}
+	if !q.BornAfter.IsZero() {
+		conds = append(conds, "birth_date >= ?")
+		args = append(args, dateValue(q.BornAfter))
+	}
+	if !q.BornBefore.IsZero() {
+		conds = append(conds, "birth_date < ?")
+		args = append(args, dateValue(q.BornBefore))
+	}
+	if q.GroupID != 0 {
+		conds = append(conds, "id IN (SELECT user_id FROM group_members WHERE group_id = ?)")
+		args = append(args, q.GroupID)
+	}
+	for _, t := range q.Tags {
+		conds = append(conds, "id IN (SELECT ut.user_id FROM user_tags ut JOIN tags t ON t.id = ut.tag_id WHERE t.name = ?)")
+		args = append(args, strings.ToLower(strings.TrimSpace(t)))
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// orderBy returns the ORDER BY clause, including its leading space.
+func (q UserQuery) orderBy() (string, error) {
+	field, dir := strings.TrimPrefix(q.Sort, "-"), ""
+	if strings.HasPrefix(q.Sort, "-") {
+		dir = " DESC"
+	}
+	if field == "" || field == "id" {
+		return " ORDER BY id" + dir, nil
+	}
+
+	col, ok := sortColumns[field]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrInvalidSort, field)
+	}
+	colDir := dir
+	if c, reversed := strings.CutPrefix(col, "-"); reversed {
+		col = c
+		if dir == "" {
+			colDir = " DESC"
+		} else {
+			colDir = ""
+		}
+	}
+	return " ORDER BY " + col + colDir + ", id" + dir, nil
+}
+
+// ParseTime reads a time filter given as a date (2006-01-02, midnight UTC)
+// or in RFC 3339 format.
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (expected 2006-01-02 or RFC 3339)", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-05-01T12:30:00+02:00", time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in)
+		if err != nil {
+			t.Fatalf("%s: expected nil error, got %v", tt.in, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.in, tt.want, got)
+		}
+	}
+
+	if _, err := ParseTime("01/05/2024"); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
+
+func TestUserQuery_OrderBy(t *testing.T) {
+	tests := map[string]string{
+		"":      " ORDER BY id",
+		"-id":   " ORDER BY id DESC",
+		"name":  " ORDER BY name, id",
+		"-age":  " ORDER BY birth_date, id DESC",
+		"age":   " ORDER BY birth_date DESC, id",
+		"email": " ORDER BY email, id",
+	}
+	for sort, want := range tests {
+		got, err := UserQuery{Sort: sort}.orderBy()
+		if err != nil || got != want {
+			t.Fatalf("sort %q: expected %q, got %q (%v)", sort, want, got, err)
+		}
+	}
+}
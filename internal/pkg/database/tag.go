@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidTag = errors.New("tags must start with a letter or digit and may contain letters, digits, '-', '_', '.' and ':', up to 64 characters")
+
+var tagRegex = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_.:\-]*$`)
+
+// maxTagLength is the length of tags.name.
+const maxTagLength = 64
+
+// Tag is a label in use, with the number of users that have it.
+type Tag struct {
+	Name  string `json:"name"`
+	Users int    `json:"users"`
+}
+
+// ValidTag reports whether name is a tag as NormalizeTags returns it.
+func ValidTag(name string) bool {
+	return tagRegex.MatchString(name) && utf8.RuneCountInString(name) <= maxTagLength && name == strings.ToLower(name)
+}
+
+// NormalizeTags lowercases and trims tags, drops empty and repeated ones
+// and sorts the rest, which is how they are stored.
+func NormalizeTags(tags []string) ([]string, error) {
+	var out []string
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if !ValidTag(t) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTag, t)
+		}
+		out = append(out, t)
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// ParseTags reads a comma separated list of tags, as typed in forms and
+// stored in CSV files.
+func ParseTags(s string) ([]string, error) {
+	return NormalizeTags(strings.Split(s, ","))
+}
+
+// FormatTags is the inverse of ParseTags.
+func FormatTags(tags []string) string {
+	return strings.Join(tags, ", ")
+}
+
+// queryer is a *sql.DB or *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// tagBatch bounds the user IDs looked up per query by loadTags.
+const tagBatch = 1000
+
+// loadTags sets the Tags of users from user_tags.
+func loadTags(ctx context.Context, q queryer, users []User) error {
+	index := make(map[int64]*User, len(users))
+	for i := range users {
+		users[i].Tags = nil
+		index[users[i].ID] = &users[i]
+	}
+
+	for start := 0; start < len(users); start += tagBatch {
+		batch := users[start:min(start+tagBatch, len(users))]
+		args := make([]any, len(batch))
+		for i, u := range batch {
+			args[i] = u.ID
+		}
+
+		rows, err := q.QueryContext(
+			ctx,
+			`SELECT ut.user_id, t.name FROM user_tags ut JOIN tags t ON t.id = ut.tag_id
+			WHERE ut.user_id IN (`+placeholders(len(args))+`) ORDER BY t.name`,
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			if u := index[id]; u != nil {
+				u.Tags = append(u.Tags, name)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveTags replaces the tags of a user with tags, creating the ones that
+// don't exist yet.
+func saveTags(ctx context.Context, tx *sql.Tx, userID int64, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_tags WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	at := now()
+	for _, t := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO tags (name, created_at) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = id`, t, at); err != nil {
+			return err
+		}
+	}
+	args := []any{userID}
+	for _, t := range tags {
+		args = append(args, t)
+	}
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO user_tags (user_id, tag_id) SELECT ?, id FROM tags WHERE name IN (`+placeholders(len(tags))+`)`,
+		args...,
+	)
+	return err
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// GetTags lists the tags that at least one user has, by name.
+func (db *DB) GetTags(ctx context.Context) (_ []Tag, err error) {
+	ctx, done := db.observe(ctx, userRepository, "GetTags")
+	defer func() { done(err) }()
+
+	rows, err := db.Conn.QueryContext(
+		ctx,
+		`SELECT t.name, COUNT(*) FROM tags t JOIN user_tags ut ON ut.tag_id = t.id GROUP BY t.id, t.name ORDER BY t.name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]Tag, 0)
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.Name, &t.Users); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// TagUsers adds the tags in add to each of the users and removes those in
+// remove, recording the changes in the audit log. It fails without
+// changing anything if one of the users doesn't exist.
+func (db *DB) TagUsers(ctx context.Context, ids []int64, add, remove []string) (err error) {
+	ctx, done := db.observe(ctx, userRepository, "TagUsers")
+	defer func() { done(err) }()
+
+	if add, err = NormalizeTags(add); err != nil {
+		return err
+	}
+	if remove, err = NormalizeTags(remove); err != nil {
+		return err
+	}
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			before, err := lockUser(ctx, tx, id)
+			if err != nil {
+				return fmt.Errorf("user %d: %w", id, err)
+			}
+
+			after := *before
+			after.Tags = slices.DeleteFunc(append(slices.Clone(before.Tags), add...), func(t string) bool {
+				return slices.Contains(remove, t)
+			})
+			after.Tags, _ = NormalizeTags(after.Tags)
+
+			changes := diffUsers(before, &after)
+			if len(changes) == 0 {
+				continue
+			}
+			at := now()
+			if err := saveTags(ctx, tx, id, after.Tags); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE users SET updated_at = ? WHERE id = ?`, at, id); err != nil {
+				return err
+			}
+			if err := recordAudit(ctx, tx, id, AuditUpdated, changes, at); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := ParseTags(" On-Call, contractor,,on-call , team:ops")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if want := []string{"contractor", "on-call", "team:ops"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	for _, bad := range []string{"two words", "-leading", "semi;colon", "x23456789012345678901234567890123456789012345678901234567890123456"} {
+		if _, err := NormalizeTags([]string{bad}); !errors.Is(err, ErrInvalidTag) {
+			t.Fatalf("%q: expected ErrInvalidTag, got %v", bad, err)
+		}
+	}
+}
+
+func TestGetUsers_TagFilterLoadsTags(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM users WHERE id IN (SELECT ut.user_id FROM user_tags ut JOIN tags t ON t.id = ut.tag_id WHERE t.name = ?) ORDER BY id LIMIT ? OFFSET ?`)).
+		WithArgs("on-call", 10, 0).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(1), "A", "a@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime).
+			AddRow(int64(2), "B", "b@test.com", birth1990, false, "", "", "", "", nil, nil, "", testTime, testTime))
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE ut.user_id IN (?, ?) ORDER BY t.name`)).
+		WithArgs(int64(1), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name"}).
+			AddRow(int64(2), "contractor").
+			AddRow(int64(1), "on-call").
+			AddRow(int64(2), "on-call"))
+
+	got, err := db.GetUsers(context.Background(), UserQuery{Tags: []string{"On-Call"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !reflect.DeepEqual(got[0].Tags, []string{"on-call"}) || !reflect.DeepEqual(got[1].Tags, []string{"contractor", "on-call"}) {
+		t.Fatalf("unexpected tags %v and %v", got[0].Tags, got[1].Tags)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestTagUsers(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ut.user_id, t.name FROM user_tags ut`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name"}).AddRow(int64(7), "intern"))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM user_tags WHERE user_id = ?`)).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO tags (name, created_at) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = id`)).
+		WithArgs("contractor", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO user_tags (user_id, tag_id) SELECT ?, id FROM tags WHERE name IN (?)`)).
+		WithArgs(int64(7), "contractor").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET updated_at = ? WHERE id = ?`)).
+		WithArgs(sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).
+		WithArgs(int64(7), AuditUpdated, `[{"field":"tags","old":"intern","new":"contractor"}]`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := db.TagUsers(context.Background(), []int64{7}, []string{"Contractor"}, []string{"intern"}); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestTagUsers_UnknownUserRollsBack(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns))
+	mock.ExpectRollback()
+
+	if err := db.TagUsers(context.Background(), []int64{7}, []string{"contractor"}, nil); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := db.TagUsers(context.Background(), []int64{7}, []string{"two words"}, nil); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
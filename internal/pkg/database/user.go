@@ -3,23 +3,177 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/text/language"
 )
 
 var ErrUserNotFound = errors.New("user not found")
 
+var (
+	ErrNameEmailRequired = errors.New("name and email are required")
+	ErrInvalidEmail      = errors.New("invalid email format")
+	ErrBirthDateRequired = errors.New("birth date is required")
+	ErrInvalidBirthDate  = errors.New("birth date must be in the past and at most 150 years ago")
+	ErrInvalidPhone      = errors.New("invalid phone number")
+	ErrInvalidLocale     = errors.New("invalid locale")
+	ErrFieldTooLong      = errors.New("department and title must be at most 255 characters")
+)
+
+var (
+	emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+	// phoneRegex accepts international numbers with the usual separators,
+	// such as "+387 61 123-456" or "(555) 123 4567".
+	phoneRegex = regexp.MustCompile(`^\+?[0-9 ()\-.]{5,31}$`)
+)
+
+// maxAge bounds birth dates in the past.
+const maxAge = 150
+
+// User is a user record. BirthDate is a date at midnight UTC; it is
+// approximate for users whose age was stored before birth dates were.
+// Phone, Department, Title and Locale are optional and empty when unset.
+// Attributes holds the custom attributes defined in the config, in the
+// canonical form of attributes.Defs.Normalize. Tags are stored in their
+// own table, sorted as NormalizeTags returns them. ManagerID is nil for
+// users without a manager. Avatar is the version of the user's avatar
+// image, empty when there is none; only SetUserAvatar changes it.
 type User struct {
-	ID    int64  `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Age   int    `json:"age"`
+	ID                   int64          `json:"id"`
+	Name                 string         `json:"name"`
+	Email                string         `json:"email"`
+	BirthDate            time.Time      `json:"birth_date"`
+	BirthDateApproximate bool           `json:"birth_date_approximate"`
+	Phone                string         `json:"phone"`
+	Department           string         `json:"department"`
+	Title                string         `json:"title"`
+	Locale               string         `json:"locale"`
+	Attributes           map[string]any `json:"attributes,omitempty"`
+	Tags                 []string       `json:"tags,omitempty"`
+	ManagerID            *int64         `json:"manager_id,omitempty"`
+	Avatar               string         `json:"avatar,omitempty"`
+	CreatedAt            time.Time      `json:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at"`
+}
+
+// userColumns are the columns scanUser reads, in order.
+const userColumns = `id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
 }
 
-func (db *DB) GetUsers(ctx context.Context, limit, offset int) ([]User, error) {
+func scanUser(s scanner, u *User) error {
+	var attrs []byte
+	var manager sql.NullInt64
+	if err := s.Scan(&u.ID, &u.Name, &u.Email, &u.BirthDate, &u.BirthDateApproximate, &u.Phone, &u.Department, &u.Title, &u.Locale, &attrs, &manager, &u.Avatar, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return err
+	}
+	u.ManagerID = nil
+	if manager.Valid {
+		u.ManagerID = &manager.Int64
+	}
+	u.Attributes = nil
+	if len(attrs) == 0 {
+		return nil
+	}
+	return json.Unmarshal(attrs, &u.Attributes)
+}
+
+// attributesValue is the attributes column for u: NULL when there are
+// none and a JSON object otherwise.
+func attributesValue(u *User) (any, error) {
+	if len(u.Attributes) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(u.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// insertUser is the statement CreateUser and CreateUsers run with the
+// arguments from insertArgs.
+const insertUser = `INSERT INTO users (name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+func insertArgs(u *User, at time.Time) ([]any, error) {
+	attrs, err := attributesValue(u)
+	if err != nil {
+		return nil, err
+	}
+	return []any{u.Name, u.Email, dateValue(u.BirthDate), u.BirthDateApproximate, u.Phone, u.Department, u.Title, u.Locale, attrs, u.ManagerID, at, at}, nil
+}
+
+// now is the time the repository stamps on changed rows. MySQL keeps
+// microseconds, so the values returned to callers match what is stored.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Validate checks the rules every user must satisfy, whether it comes from
+// the web form, the command line or an import.
+func (u *User) Validate() error {
+	if u.Name == "" || u.Email == "" {
+		return ErrNameEmailRequired
+	}
+	if !emailRegex.MatchString(u.Email) {
+		return ErrInvalidEmail
+	}
+	if u.BirthDate.IsZero() {
+		return ErrBirthDateRequired
+	}
+	today := Today()
+	if u.BirthDate.After(today) || u.BirthDate.Before(today.AddDate(-maxAge, 0, 0)) {
+		return ErrInvalidBirthDate
+	}
+	if u.Phone != "" && !phoneRegex.MatchString(u.Phone) {
+		return ErrInvalidPhone
+	}
+	if u.Locale != "" {
+		if _, err := language.Parse(u.Locale); err != nil {
+			return ErrInvalidLocale
+		}
+	}
+	if utf8.RuneCountInString(u.Department) > 255 || utf8.RuneCountInString(u.Title) > 255 {
+		return ErrFieldTooLong
+	}
+	if _, err := NormalizeTags(u.Tags); err != nil {
+		return ErrInvalidTag
+	}
+	if u.ManagerID != nil && u.ID != 0 && *u.ManagerID == u.ID {
+		return ErrManagerCycle
+	}
+	return nil
+}
+
+// IsDuplicate reports whether err is MySQL rejecting a row that violates a
+// unique key, such as an email that is already taken.
+func IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+func (db *DB) GetUsers(ctx context.Context, q UserQuery) (_ []User, err error) {
+	ctx, done := db.observe(ctx, userRepository, "GetUsers")
+	defer func() { done(err) }()
+
+	orderBy, err := q.orderBy()
+	if err != nil {
+		return nil, err
+	}
+	where, args := q.where()
 	rows, err := db.Conn.QueryContext(
 		ctx,
-		`SELECT id, name, email, age FROM users ORDER BY id LIMIT ? OFFSET ?`,
-		limit, offset,
+		`SELECT `+userColumns+` FROM users`+where+orderBy+` LIMIT ? OFFSET ?`,
+		append(args, q.Limit, q.Offset)...,
 	)
 	if err != nil {
 		return nil, err
@@ -29,21 +183,40 @@ func (db *DB) GetUsers(ctx context.Context, limit, offset int) ([]User, error) {
 	users := make([]User, 0)
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Age); err != nil {
+		if err := scanUser(rows, &u); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
 	}
-	return users, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := loadTags(ctx, db.Conn, users); err != nil {
+		return nil, err
+	}
+	return users, nil
 }
 
-func (db *DB) GetUserByID(ctx context.Context, id int64) (*User, error) {
+func (db *DB) CountUsers(ctx context.Context) (n int64, err error) {
+	ctx, done := db.observe(ctx, userRepository, "CountUsers")
+	defer func() { done(err) }()
+
+	err = db.Conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&n)
+	return n, err
+}
+
+func (db *DB) GetUserByID(ctx context.Context, id int64) (_ *User, err error) {
+	ctx, done := db.observe(ctx, userRepository, "GetUserByID")
+	defer func() { done(err) }()
+
 	u := &User{}
-	err := db.Conn.QueryRowContext(
+	err = scanUser(db.Conn.QueryRowContext(
 		ctx,
-		`SELECT id, name, email, age FROM users WHERE id = ?`,
+		`SELECT `+userColumns+` FROM users WHERE id = ?`,
 		id,
-	).Scan(&u.ID, &u.Name, &u.Email, &u.Age)
+	), u)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -52,64 +225,283 @@ func (db *DB) GetUserByID(ctx context.Context, id int64) (*User, error) {
 		return nil, err
 	}
 
-	return u, nil
+	return u, db.loadUserTags(ctx, db.Conn, u)
 }
 
-func (db *DB) CreateUser(ctx context.Context, u *User) error {
-	res, err := db.Conn.ExecContext(
+func (db *DB) GetUserByEmail(ctx context.Context, email string) (_ *User, err error) {
+	ctx, done := db.observe(ctx, userRepository, "GetUserByEmail")
+	defer func() { done(err) }()
+
+	u := &User{}
+	err = scanUser(db.Conn.QueryRowContext(
 		ctx,
-		`INSERT INTO users (name, email, age) VALUES (?, ?, ?)`,
-		u.Name, u.Email, u.Age,
-	)
+		`SELECT `+userColumns+` FROM users WHERE email = ?`,
+		email,
+	), u)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return u, db.loadUserTags(ctx, db.Conn, u)
+}
 
-	id, err := res.LastInsertId()
-	if err != nil {
+// loadUserTags sets the Tags of a single user.
+func (db *DB) loadUserTags(ctx context.Context, q queryer, u *User) error {
+	users := []User{*u}
+	if err := loadTags(ctx, q, users); err != nil {
 		return err
 	}
-	u.ID = id
+	u.Tags = users[0].Tags
 	return nil
-
 }
 
-func (db *DB) UpdateUser(ctx context.Context, u *User) error {
-	res, err := db.Conn.ExecContext(
-		ctx,
-		`UPDATE users SET name = ?, email = ?, age = ? WHERE id = ?`,
-		u.Name, u.Email, u.Age, u.ID,
-	)
+// inTx runs fn in a transaction, which is committed if fn returns nil and
+// rolled back otherwise.
+func (db *DB) inTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.Conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
 
-	affected, err := res.RowsAffected()
-	if err != nil {
+	if err = fn(tx); err != nil {
 		return err
 	}
+	return tx.Commit()
+}
 
-	if affected == 0 {
-		return ErrUserNotFound
+// lockUser reads a user with its tags and locks its row until the
+// transaction ends.
+func lockUser(ctx context.Context, tx *sql.Tx, id int64) (*User, error) {
+	u := &User{}
+	err := scanUser(tx.QueryRowContext(
+		ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = ? FOR UPDATE`,
+		id,
+	), u)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
 	}
-
-	return nil
+	if err != nil {
+		return nil, err
+	}
+	users := []User{*u}
+	if err := loadTags(ctx, tx, users); err != nil {
+		return nil, err
+	}
+	return &users[0], nil
 }
 
-func (db *DB) DeleteUser(ctx context.Context, id int64) error {
-	res, err := db.Conn.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
-	if err != nil {
+func (db *DB) CreateUser(ctx context.Context, u *User) (err error) {
+	ctx, done := db.observe(ctx, userRepository, "CreateUser")
+	defer func() { done(err) }()
+
+	if u.Tags, err = NormalizeTags(u.Tags); err != nil {
 		return err
 	}
 
-	affected, err := res.RowsAffected()
-	if err != nil {
+	u.Avatar = ""
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := checkManager(ctx, tx, 0, u.ManagerID); err != nil {
+			return err
+		}
+		at := now()
+		args, err := insertArgs(u, at)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, insertUser, args...)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		u.ID, u.CreatedAt, u.UpdatedAt = id, at, at
+		if len(u.Tags) > 0 {
+			if err := saveTags(ctx, tx, id, u.Tags); err != nil {
+				return err
+			}
+		}
+		return recordAudit(ctx, tx, id, AuditCreated, diffUsers(nil, u), at)
+	})
+}
+
+func (db *DB) UpdateUser(ctx context.Context, u *User) (err error) {
+	ctx, done := db.observe(ctx, userRepository, "UpdateUser")
+	defer func() { done(err) }()
+
+	if u.Tags, err = NormalizeTags(u.Tags); err != nil {
 		return err
 	}
 
-	if affected == 0 {
-		return ErrUserNotFound
-	}
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockUser(ctx, tx, u.ID)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		u.Avatar, u.CreatedAt, u.UpdatedAt = before.Avatar, before.CreatedAt, before.UpdatedAt
+		changes := diffUsers(before, u)
+		if len(changes) == 0 {
+			return nil
+		}
+		if !sameManager(before, u) {
+			if err := checkManager(ctx, tx, u.ID, u.ManagerID); err != nil {
+				return err
+			}
+		}
+
+		attrs, err := attributesValue(u)
+		if err != nil {
+			return err
+		}
+		at := now()
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE users SET name = ?, email = ?, birth_date = ?, birth_date_approximate = ?, phone = ?, department = ?, title = ?, locale = ?, attributes = ?, manager_id = ?, updated_at = ? WHERE id = ?`,
+			u.Name, u.Email, dateValue(u.BirthDate), u.BirthDateApproximate, u.Phone, u.Department, u.Title, u.Locale, attrs, u.ManagerID, at, u.ID,
+		); err != nil {
+			return err
+		}
+		if !slices.Equal(before.Tags, u.Tags) {
+			if err := saveTags(ctx, tx, u.ID, u.Tags); err != nil {
+				return err
+			}
+		}
+		u.UpdatedAt = at
+		return recordAudit(ctx, tx, u.ID, AuditUpdated, changes, at)
+	})
+}
+
+// DeleteUser removes a user. Its reports are left without a manager.
+func (db *DB) DeleteUser(ctx context.Context, id int64) (err error) {
+	ctx, done := db.observe(ctx, userRepository, "DeleteUser")
+	defer func() { done(err) }()
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, id, AuditDeleted, diffUsers(before, nil), now())
+	})
+}
+
+// CreateUsers inserts users in a single transaction and sets their IDs.
+// Either all of them are created or none.
+func (db *DB) CreateUsers(ctx context.Context, users []User) (err error) {
+	ctx, done := db.observe(ctx, userRepository, "CreateUsers")
+	defer func() { done(err) }()
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, insertUser)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		at := now()
+		for i := range users {
+			u := &users[i]
+			u.Avatar = ""
+			if u.Tags, err = NormalizeTags(u.Tags); err != nil {
+				return fmt.Errorf("user %d (%s): %w", i+1, u.Email, err)
+			}
+			if err := checkManager(ctx, tx, 0, u.ManagerID); err != nil {
+				return fmt.Errorf("user %d (%s): %w", i+1, u.Email, err)
+			}
+			args, err := insertArgs(u, at)
+			if err != nil {
+				return err
+			}
+			res, err := stmt.ExecContext(ctx, args...)
+			if err != nil {
+				return fmt.Errorf("user %d (%s): %w", i+1, u.Email, err)
+			}
+			if u.ID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+			u.CreatedAt, u.UpdatedAt = at, at
+			if len(u.Tags) > 0 {
+				if err := saveTags(ctx, tx, u.ID, u.Tags); err != nil {
+					return err
+				}
+			}
+			if err := recordAudit(ctx, tx, u.ID, AuditCreated, diffUsers(nil, u), at); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteAllUsers removes every user and returns how many there were, and
+// the avatar versions of those that had one, by user id, so the caller
+// can delete the files.
+func (db *DB) DeleteAllUsers(ctx context.Context) (n int64, avatars map[int64]string, err error) {
+	ctx, done := db.observe(ctx, userRepository, "DeleteAllUsers")
+	defer func() { done(err) }()
+
+	err = db.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT `+userColumns+` FROM users FOR UPDATE`)
+		if err != nil {
+			return err
+		}
+		var users []User
+		for rows.Next() {
+			var u User
+			if err := scanUser(rows, &u); err != nil {
+				rows.Close()
+				return err
+			}
+			users = append(users, u)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := loadTags(ctx, tx, users); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM users`)
+		if err != nil {
+			return err
+		}
+		if n, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		at := now()
+		for i := range users {
+			if err := recordAudit(ctx, tx, users[i].ID, AuditDeleted, diffUsers(&users[i], nil), at); err != nil {
+				return err
+			}
+		}
+
+		avatars = make(map[int64]string)
+		for _, u := range users {
+			if u.Avatar != "" {
+				avatars[u.ID] = u.Avatar
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, avatars, nil
 }
@@ -3,12 +3,26 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 )
 
+var testTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+var (
+	birth2000 = time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	birth1990 = time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC)
+)
+
+var userRowColumns = []string{"id", "name", "email", "birth_date", "birth_date_approximate", "phone", "department", "title", "locale", "attributes", "manager_id", "avatar", "created_at", "updated_at"}
+
 func newMockDB(t *testing.T) (*DB, sqlmock.Sqlmock, func()) {
 	t.Helper()
 
@@ -21,21 +35,30 @@ func newMockDB(t *testing.T) (*DB, sqlmock.Sqlmock, func()) {
 	return &DB{Conn: conn}, mock, cleanup
 }
 
+// expectTags expects the query loading the tags of users, which have
+// none.
+func expectTags(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ut.user_id, t.name FROM user_tags ut`)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name"}))
+}
+
 func TestGetUsers(t *testing.T) {
 	db, mock, cleanup := newMockDB(t)
 	defer cleanup()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "age"}).
-		AddRow(int64(1), "A", "a@test.com", 20).
-		AddRow(int64(2), "B", "b@test.com", 30)
+	rows := sqlmock.NewRows(userRowColumns).
+		AddRow(int64(1), "A", "a@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime).
+		AddRow(int64(2), "B", "b@test.com", birth1990, false, "", "", "", "", nil, nil, "", testTime, testTime)
 
 	mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT id, name, email, age FROM users ORDER BY id LIMIT ? OFFSET ?`,
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users ORDER BY id LIMIT ? OFFSET ?`,
 	)).
 		WithArgs(10, 0).
 		WillReturnRows(rows)
 
-	got, err := db.GetUsers(context.Background(), 10, 0)
+	expectTags(mock)
+
+	got, err := db.GetUsers(context.Background(), UserQuery{Limit: 10})
 	if err != nil {
 		t.Fatalf("expected nil error, got %v", err)
 	}
@@ -51,12 +74,78 @@ func TestGetUsers(t *testing.T) {
 	}
 }
 
+func TestGetUsers_Search(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users WHERE (name LIKE ? OR email LIKE ?) ORDER BY id LIMIT ? OFFSET ?`,
+	)).
+		WithArgs(`%50\%\_off%`, `%50\%\_off%`, 5, 10).
+		WillReturnRows(sqlmock.NewRows(userRowColumns))
+
+	if _, err := db.GetUsers(context.Background(), UserQuery{Search: " 50%_off ", Limit: 5, Offset: 10}); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGetUsers_SortAndCreatedRange(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users WHERE created_at >= ? AND created_at < ? ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+	)).
+		WithArgs(after, before, 10, 0).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(1), "A", "a@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime))
+
+	expectTags(mock)
+
+	got, err := db.GetUsers(context.Background(), UserQuery{
+		CreatedAfter:  after,
+		CreatedBefore: before,
+		Sort:          "-created_at",
+		Limit:         10,
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !got[0].CreatedAt.Equal(testTime) {
+		t.Fatalf("expected created_at %v, got %v", testTime, got[0].CreatedAt)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGetUsers_InvalidSort(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	_, err := db.GetUsers(context.Background(), UserQuery{Sort: "password; DROP TABLE users"})
+	if !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("expected ErrInvalidSort, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
 func TestGetUserByID_NotFound(t *testing.T) {
 	db, mock, cleanup := newMockDB(t)
 	defer cleanup()
 
 	mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT id, name, email, age FROM users WHERE id = ?`,
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users WHERE id = ?`,
 	)).
 		WithArgs(int64(999)).
 		WillReturnError(sql.ErrNoRows)
@@ -78,13 +167,18 @@ func TestCreateUser_SetsID(t *testing.T) {
 	db, mock, cleanup := newMockDB(t)
 	defer cleanup()
 
+	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(
-		`INSERT INTO users (name, email, age) VALUES (?, ?, ?)`,
+		insertUser,
 	)).
-		WithArgs("Mahir", "mahir@test.com", 24).
+		WithArgs("Mahir", "mahir@test.com", "2000-01-02", false, "", "", "", "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).
+		WithArgs(int64(7), AuditCreated, `[{"field":"name","new":"Mahir"},{"field":"email","new":"mahir@test.com"},{"field":"birth_date","new":"2000-01-02"}]`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	u := &User{Name: "Mahir", Email: "mahir@test.com", Age: 24}
+	u := &User{Name: "Mahir", Email: "mahir@test.com", BirthDate: birth2000}
 	err := db.CreateUser(context.Background(), u)
 	if err != nil {
 		t.Fatalf("expected nil error, got %v", err)
@@ -92,6 +186,9 @@ func TestCreateUser_SetsID(t *testing.T) {
 	if u.ID != 7 {
 		t.Fatalf("expected ID=7, got %d", u.ID)
 	}
+	if u.CreatedAt.IsZero() || !u.UpdatedAt.Equal(u.CreatedAt) {
+		t.Fatalf("expected matching timestamps, got %v and %v", u.CreatedAt, u.UpdatedAt)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet sqlmock expectations: %v", err)
@@ -102,13 +199,15 @@ func TestUpdateUser_NotFound(t *testing.T) {
 	db, mock, cleanup := newMockDB(t)
 	defer cleanup()
 
-	mock.ExpectExec(regexp.QuoteMeta(
-		`UPDATE users SET name = ?, email = ?, age = ? WHERE id = ?`,
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users WHERE id = ? FOR UPDATE`,
 	)).
-		WithArgs("X", "x@test.com", 10, int64(123)).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+		WithArgs(int64(123)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
-	u := &User{ID: 123, Name: "X", Email: "x@test.com", Age: 10}
+	u := &User{ID: 123, Name: "X", Email: "x@test.com", BirthDate: birth2000}
 	err := db.UpdateUser(context.Background(), u)
 	if err == nil {
 		t.Fatalf("expected error, got nil")
@@ -126,11 +225,13 @@ func TestDeleteUser_NotFound(t *testing.T) {
 	db, mock, cleanup := newMockDB(t)
 	defer cleanup()
 
-	mock.ExpectExec(regexp.QuoteMeta(
-		`DELETE FROM users WHERE id = ?`,
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users WHERE id = ? FOR UPDATE`,
 	)).
 		WithArgs(int64(123)).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
 	err := db.DeleteUser(context.Background(), 123)
 	if err == nil {
@@ -144,3 +245,294 @@ func TestDeleteUser_NotFound(t *testing.T) {
 		t.Fatalf("unmet sqlmock expectations: %v", err)
 	}
 }
+
+func TestCountUsers(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM users`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(3)))
+
+	n, err := db.CountUsers(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 users, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestCreateUsers_CommitsAndSetsIDs(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	insert := regexp.QuoteMeta(insertUser)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(insert)
+	prep.ExpectExec().WithArgs("A", "a@test.com", "2000-01-02", false, "", "", "", "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).WithArgs(int64(3), AuditCreated, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs("B", "b@test.com", "1990-06-15", false, "", "", "", "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).WithArgs(int64(4), AuditCreated, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	users := []User{
+		{Name: "A", Email: "a@test.com", BirthDate: birth2000},
+		{Name: "B", Email: "b@test.com", BirthDate: birth1990},
+	}
+	if err := db.CreateUsers(context.Background(), users); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if users[0].ID != 3 || users[1].ID != 4 {
+		t.Fatalf("expected IDs 3 and 4, got %d and %d", users[0].ID, users[1].ID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestCreateUsers_RollsBackOnError(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	insert := regexp.QuoteMeta(insertUser)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(insert)
+	prep.ExpectExec().WithArgs("A", "a@test.com", "2000-01-02", false, "", "", "", "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).WithArgs(int64(3), AuditCreated, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs("A", "a@test.com", "2000-01-02", false, "", "", "", "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	users := []User{
+		{Name: "A", Email: "a@test.com", BirthDate: birth2000},
+		{Name: "A", Email: "a@test.com", BirthDate: birth2000},
+	}
+	err := db.CreateUsers(context.Background(), users)
+	if !IsDuplicate(err) {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestDeleteAllUsers(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users FOR UPDATE`)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(1), "A", "a@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime).
+			AddRow(int64(2), "B", "b@test.com", birth1990, false, "", "", "", "", nil, nil, "v1", testTime, testTime))
+	expectTags(mock)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM users`)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).WithArgs(int64(1), AuditDeleted, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).WithArgs(int64(2), AuditDeleted, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	n, avatars, err := db.DeleteAllUsers(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 deleted, got %d", n)
+	}
+	if !reflect.DeepEqual(avatars, map[int64]string{2: "v1"}) {
+		t.Fatalf("expected the avatar of user 2, got %v", avatars)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestUpdateUser_RecordsChangedFields(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users WHERE id = ? FOR UPDATE`,
+	)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime))
+	expectTags(mock)
+	mock.ExpectExec(regexp.QuoteMeta(
+		`UPDATE users SET name = ?, email = ?, birth_date = ?, birth_date_approximate = ?, phone = ?, department = ?, title = ?, locale = ?, attributes = ?, manager_id = ?, updated_at = ? WHERE id = ?`,
+	)).
+		WithArgs("Mahir", "mahir@test.com", "1990-06-15", false, "", "", "", "", nil, nil, sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).
+		WithArgs(int64(7), AuditUpdated, `[{"field":"birth_date","old":"2000-01-02","new":"1990-06-15"}]`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	u := &User{ID: 7, Name: "Mahir", Email: "mahir@test.com", BirthDate: birth1990}
+	if err := db.UpdateUser(context.Background(), u); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestUpdateUser_UnchangedSkipsWrite(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users WHERE id = ? FOR UPDATE`,
+	)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "", "", "", nil, nil, "", testTime, testTime))
+	expectTags(mock)
+	mock.ExpectCommit()
+
+	u := &User{ID: 7, Name: "Mahir", Email: "mahir@test.com", BirthDate: birth2000}
+	if err := db.UpdateUser(context.Background(), u); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestGetUserAudit(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	at := testTime
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, user_id, action, changes, created_at FROM user_audit WHERE user_id = ? ORDER BY id`,
+	)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "action", "changes", "created_at"}).
+			AddRow(int64(1), int64(7), AuditUpdated, []byte(`[{"field":"age","old":"24","new":"25"}]`), at))
+
+	got, err := db.GetUserAudit(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 1 || got[0].Action != AuditUpdated || !got[0].At.Equal(at) {
+		t.Fatalf("unexpected entries %+v", got)
+	}
+	if c := got[0].Changes; len(c) != 1 || c[0] != (FieldChange{Field: "age", Old: "24", New: "25"}) {
+		t.Fatalf("unexpected changes %+v", c)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestUpdateUser_ProfileAndAttributes(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, name, email, birth_date, birth_date_approximate, phone, department, title, locale, attributes, manager_id, avatar, created_at, updated_at FROM users WHERE id = ? FOR UPDATE`,
+	)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth2000, false, "", "IT", "", "bs", []byte(`{"remote":true}`), nil, "", testTime, testTime))
+	expectTags(mock)
+	mock.ExpectExec(regexp.QuoteMeta(
+		`UPDATE users SET name = ?, email = ?, birth_date = ?, birth_date_approximate = ?, phone = ?, department = ?, title = ?, locale = ?, attributes = ?, manager_id = ?, updated_at = ? WHERE id = ?`,
+	)).
+		WithArgs("Mahir", "mahir@test.com", "2000-01-02", false, "+387 61 123 456", "IT", "", "bs", `{"cost_center":"Ops","remote":true}`, nil, sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).
+		WithArgs(int64(7), AuditUpdated,
+			`[{"field":"phone","new":"+387 61 123 456"},{"field":"attributes","old":"{\"remote\":true}","new":"{\"cost_center\":\"Ops\",\"remote\":true}"}]`,
+			sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	u := &User{
+		ID: 7, Name: "Mahir", Email: "mahir@test.com", BirthDate: birth2000,
+		Phone: "+387 61 123 456", Department: "IT", Locale: "bs",
+		Attributes: map[string]any{"remote": true, "cost_center": "Ops"},
+	}
+	if err := db.UpdateUser(context.Background(), u); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestUser_Validate(t *testing.T) {
+	valid := User{Name: "A", Email: "a@test.com", BirthDate: birth2000}
+	tests := []struct {
+		name string
+		edit func(u *User)
+		want error
+	}{
+		{"minimal", func(u *User) {}, nil},
+		{"profile", func(u *User) { u.Phone, u.Locale, u.Title = "+1 (555) 123-4567", "en-US", "Engineer" }, nil},
+		{"phone", func(u *User) { u.Phone = "call me" }, ErrInvalidPhone},
+		{"locale", func(u *User) { u.Locale = "not a locale" }, ErrInvalidLocale},
+		{"title", func(u *User) { u.Title = strings.Repeat("x", 256) }, ErrFieldTooLong},
+		{"no birth date", func(u *User) { u.BirthDate = time.Time{} }, ErrBirthDateRequired},
+		{"born tomorrow", func(u *User) { u.BirthDate = Today().AddDate(0, 0, 1) }, ErrInvalidBirthDate},
+		{"born today", func(u *User) { u.BirthDate = Today() }, nil},
+		{"too old", func(u *User) { u.BirthDate = Today().AddDate(-151, 0, 0) }, ErrInvalidBirthDate},
+		{"tags", func(u *User) { u.Tags = []string{"On-Call", "contractor"} }, nil},
+		{"tag", func(u *User) { u.Tags = []string{"two words"} }, ErrInvalidTag},
+	}
+	for _, tt := range tests {
+		u := valid
+		tt.edit(&u)
+		if err := u.Validate(); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestUpdateUser_ApproximateBirthDate(t *testing.T) {
+	db, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + userColumns + ` FROM users WHERE id = ? FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userRowColumns).
+			AddRow(int64(7), "Mahir", "mahir@test.com", birth1990, true, "", "", "", "", nil, nil, "", testTime, testTime))
+	expectTags(mock)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET`)).
+		WithArgs("Mahir", "mahir@test.com", "1990-06-15", false, "", "", "", "", nil, nil, sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertAudit)).
+		WithArgs(int64(7), AuditUpdated, `[{"field":"birth_date_approximate","old":"true"}]`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	u := &User{ID: 7, Name: "Mahir", Email: "mahir@test.com", BirthDate: birth1990}
+	if err := db.UpdateUser(context.Background(), u); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
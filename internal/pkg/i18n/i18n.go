@@ -0,0 +1,199 @@
+// Package i18n provides the UI message catalogs and picks a locale for a
+// request.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+//go:embed locales/*.json
+var catalogs embed.FS
+
+// DefaultLocale is used when nothing the client asks for is available, and
+// for messages missing from another catalog.
+const DefaultLocale = "en"
+
+// Localizer translates messages and formats values for one locale. A nil
+// Localizer behaves like the default locale.
+type Localizer struct {
+	tag      language.Tag
+	messages map[string]string
+	fallback map[string]string
+	printer  *message.Printer
+}
+
+// Lang returns the BCP 47 tag, e.g. "bs".
+func (l *Localizer) Lang() string {
+	return l.orDefault().tag.String()
+}
+
+// Name returns the language's own name, e.g. "Bosanski".
+func (l *Localizer) Name() string {
+	return l.T("language.name")
+}
+
+// T returns the message for key with args formatted in. Numbers in args use
+// the locale's separators. Unknown keys are returned as is.
+func (l *Localizer) T(key string, args ...any) string {
+	l = l.orDefault()
+
+	msg, ok := l.messages[key]
+	if !ok {
+		msg, ok = l.fallback[key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return l.printer.Sprintf(msg, args...)
+}
+
+// Number formats n with the locale's digit grouping.
+func (l *Localizer) Number(n any) string {
+	return l.orDefault().printer.Sprint(n)
+}
+
+func (l *Localizer) Date(t time.Time) string {
+	return t.Format(l.T("format.date"))
+}
+
+func (l *Localizer) DateTime(t time.Time) string {
+	return t.Format(l.T("format.datetime"))
+}
+
+func (l *Localizer) orDefault() *Localizer {
+	if l == nil {
+		return Default().localizers[0]
+	}
+	return l
+}
+
+// Bundle holds a Localizer per bundled locale, the default one first.
+type Bundle struct {
+	localizers []*Localizer
+	matcher    language.Matcher
+}
+
+// Default returns the bundle built from the embedded catalogs.
+var Default = sync.OnceValue(func() *Bundle {
+	b, err := load(catalogs)
+	if err != nil {
+		panic(fmt.Sprintf("i18n: %v", err))
+	}
+	return b
+})
+
+func load(fsys fs.FS) (*Bundle, error) {
+	files, err := fs.Glob(fsys, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	all := make(map[string]map[string]string, len(files))
+	for _, f := range files {
+		raw, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		all[strings.TrimSuffix(path.Base(f), ".json")] = messages
+	}
+
+	fallback, ok := all[DefaultLocale]
+	if !ok {
+		return nil, fmt.Errorf("missing catalog for %s", DefaultLocale)
+	}
+
+	langs := make([]string, 0, len(all))
+	for lang := range all {
+		if lang != DefaultLocale {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	langs = append([]string{DefaultLocale}, langs...)
+
+	b := &Bundle{}
+	tags := make([]language.Tag, 0, len(langs))
+	for _, lang := range langs {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return nil, fmt.Errorf("catalog %s: %w", lang, err)
+		}
+		tags = append(tags, tag)
+		b.localizers = append(b.localizers, &Localizer{
+			tag:      tag,
+			messages: all[lang],
+			fallback: fallback,
+			printer:  message.NewPrinter(tag),
+		})
+	}
+	b.matcher = language.NewMatcher(tags)
+
+	return b, nil
+}
+
+// Locales returns every bundled locale, the default one first.
+func (b *Bundle) Locales() []*Localizer {
+	return b.localizers
+}
+
+// Match returns the Localizer for the first preference that matches a
+// bundled locale. Each preference is a language tag or an Accept-Language
+// header value; empty ones are skipped.
+func (b *Bundle) Match(prefs ...string) *Localizer {
+	for _, pref := range prefs {
+		if pref == "" {
+			continue
+		}
+		tags, _, err := language.ParseAcceptLanguage(pref)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+		if _, i, conf := b.matcher.Match(tags...); conf != language.No {
+			return b.localizers[i]
+		}
+	}
+	return b.localizers[0]
+}
+
+// Lookup returns the Localizer for exactly lang, if bundled.
+func (b *Bundle) Lookup(lang string) (*Localizer, bool) {
+	for _, l := range b.localizers {
+		if l.tag.String() == lang {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+type ctxKey struct{}
+
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the request's Localizer, or the default one.
+func FromContext(ctx context.Context) *Localizer {
+	if l, ok := ctx.Value(ctxKey{}).(*Localizer); ok {
+		return l
+	}
+	return Default().localizers[0]
+}
@@ -0,0 +1,73 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCatalogs_HaveSameKeys(t *testing.T) {
+	b := Default()
+	en := b.Locales()[0]
+	if en.Lang() != DefaultLocale {
+		t.Fatalf("expected %s first, got %s", DefaultLocale, en.Lang())
+	}
+
+	for _, l := range b.Locales()[1:] {
+		for key := range en.messages {
+			if _, ok := l.messages[key]; !ok {
+				t.Fatalf("%s catalog is missing %q", l.Lang(), key)
+			}
+		}
+		for key := range l.messages {
+			if _, ok := en.messages[key]; !ok {
+				t.Fatalf("%s catalog has unknown key %q", l.Lang(), key)
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	b := Default()
+
+	tests := []struct {
+		prefs []string
+		want  string
+	}{
+		{[]string{"bs", "", "en"}, "bs"},
+		{[]string{"", "bs", "en"}, "bs"},
+		{[]string{"xx", "", "de-DE,bs;q=0.8,en;q=0.5"}, "bs"},
+		{[]string{"", "", "de-DE"}, "en"},
+		{nil, "en"},
+	}
+	for _, tt := range tests {
+		if got := b.Match(tt.prefs...).Lang(); got != tt.want {
+			t.Fatalf("Match(%q): expected %s, got %s", tt.prefs, tt.want, got)
+		}
+	}
+}
+
+func TestLocalizer_Formatting(t *testing.T) {
+	bs, ok := Default().Lookup("bs")
+	if !ok {
+		t.Fatalf("expected bs to be bundled")
+	}
+
+	if got := bs.T("pagination.page", 1234); got != "Stranica 1.234" {
+		t.Fatalf("unexpected translation %q", got)
+	}
+	if got := bs.Date(time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)); got != "7. 3. 2024." {
+		t.Fatalf("unexpected date %q", got)
+	}
+	if got := bs.T("no.such.key"); got != "no.such.key" {
+		t.Fatalf("expected unknown key back, got %q", got)
+	}
+
+	var none *Localizer
+	if got := none.Number(1234567); got != "1,234,567" {
+		t.Fatalf("expected default locale grouping, got %q", got)
+	}
+	if got := FromContext(context.Background()).T("validation.invalid_email"); got != "invalid email format" {
+		t.Fatalf("expected English default, got %q", got)
+	}
+}
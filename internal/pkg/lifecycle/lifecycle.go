@@ -0,0 +1,75 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrTimeout is returned by Shutdown when the deadline passed before every
+// hook finished.
+var ErrTimeout = errors.New("shutdown timed out")
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Shutdown runs cleanup hooks in the order they were added, so components
+// are stopped in the reverse order of their dependencies: register the HTTP
+// server before the jobs it feeds and the database last.
+type Shutdown struct {
+	hooks  []hook
+	logger *slog.Logger
+}
+
+func NewShutdown(logger *slog.Logger) *Shutdown {
+	return &Shutdown{logger: logger}
+}
+
+func (s *Shutdown) Add(name string, fn func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, hook{name: name, fn: fn})
+}
+
+// Run calls every hook even if an earlier one failed, giving each the
+// remaining time of ctx. It returns ErrTimeout if ctx expired, otherwise
+// the hook errors joined together.
+func (s *Shutdown) Run(ctx context.Context) error {
+	var errs []error
+
+	for _, h := range s.hooks {
+		if ctx.Err() != nil {
+			s.logger.Error("shutdown hook skipped", "hook", h.name, "error", ctx.Err())
+			continue
+		}
+
+		start := time.Now()
+		err := runHook(ctx, h)
+		if err != nil {
+			s.logger.Error("shutdown hook failed", "hook", h.name, "duration", time.Since(start), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		s.logger.Info("shutdown hook done", "hook", h.name, "duration", time.Since(start))
+	}
+
+	if ctx.Err() != nil {
+		return ErrTimeout
+	}
+	return errors.Join(errs...)
+}
+
+// runHook returns as soon as ctx expires even if the hook ignores it.
+func runHook(ctx context.Context, h hook) error {
+	done := make(chan error, 1)
+	go func() { done <- h.fn(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
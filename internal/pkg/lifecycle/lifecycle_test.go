@@ -0,0 +1,60 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShutdown_RunsHooksInOrder(t *testing.T) {
+	s := NewShutdown(slog.New(slog.DiscardHandler))
+
+	var order []string
+	for _, name := range []string{"http", "jobs", "database"} {
+		s.Add(name, func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !reflect.DeepEqual(order, []string{"http", "jobs", "database"}) {
+		t.Fatalf("unexpected order %v", order)
+	}
+}
+
+func TestShutdown_ContinuesAfterFailure(t *testing.T) {
+	s := NewShutdown(slog.New(slog.DiscardHandler))
+
+	ran := false
+	s.Add("http", func(ctx context.Context) error { return errors.New("boom") })
+	s.Add("database", func(ctx context.Context) error { ran = true; return nil })
+
+	err := s.Run(context.Background())
+	if err == nil || errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+	if !ran {
+		t.Fatalf("expected later hooks to run")
+	}
+}
+
+func TestShutdown_Timeout(t *testing.T) {
+	s := NewShutdown(slog.New(slog.DiscardHandler))
+	s.Add("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := s.Run(ctx); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"goapp/internal/pkg/config"
+)
+
+func TestNew_JSONIncludesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger, _, err := New(&buf, config.LogConfig{Level: "debug", Format: "json"})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.With("component", "test").DebugContext(ctx, "hello")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected JSON log line, got %q", buf.String())
+	}
+	if line["request_id"] != "abc123" {
+		t.Fatalf("expected request_id abc123, got %v", line["request_id"])
+	}
+	if line["component"] != "test" {
+		t.Fatalf("expected component attr, got %v", line["component"])
+	}
+}
+
+func TestNew_RespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger, level, err := New(&buf, config.LogConfig{Level: "warn", Format: "text"})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	logger.Info("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("expected info to be filtered, got %q", buf.String())
+	}
+
+	level.Set(slog.LevelInfo)
+	logger.Info("kept")
+	if buf.Len() == 0 {
+		t.Fatalf("expected info after lowering the level")
+	}
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	if _, _, err := New(&bytes.Buffer{}, config.LogConfig{Level: "info", Format: "xml"}); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
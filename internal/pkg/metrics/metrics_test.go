@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_ExposesRecordedMetrics(t *testing.T) {
+	m := New()
+	m.ObserveHTTPRequest(http.MethodGet, "/users/{id}", http.StatusNotFound, 20*time.Millisecond)
+	m.ObserveDBQuery("GetUserByID", 5*time.Millisecond, true)
+	m.RegisterUserCount(func(ctx context.Context) (int64, error) { return 42, nil })
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`goapp_http_requests_total{method="GET",route="/users/{id}",status="404"} 1`,
+		`goapp_http_request_duration_seconds_count{method="GET",route="/users/{id}",status="404"} 1`,
+		`goapp_db_query_errors_total{method="GetUserByID"} 1`,
+		`goapp_db_query_duration_seconds_count{method="GetUserByID"} 1`,
+		`goapp_users_total 42`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected metrics output to contain %q", want)
+		}
+	}
+}
+
+func TestNilMetrics_IsNoop(t *testing.T) {
+	var m *Metrics
+	m.ObserveHTTPRequest(http.MethodGet, "/users", http.StatusOK, time.Millisecond)
+	m.ObserveDBQuery("GetUsers", time.Millisecond, false)
+}
@@ -0,0 +1,138 @@
+// Package seed generates realistic sample users for development and for
+// exercising pagination.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"goapp/internal/pkg/database"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var firstNames = []string{
+	"Amina", "Adnan", "Ajla", "Almir", "Anna", "Benjamin", "Chloe", "Daniel",
+	"Dino", "Elif", "Emina", "Emir", "Emma", "Farah", "Haris", "Ivan",
+	"Ivana", "James", "Jasmin", "Kenan", "Lamija", "Lejla", "Liam", "Lucas",
+	"Marko", "Maja", "Mia", "Mirza", "Nadia", "Nermin", "Noah", "Olivia",
+	"Petra", "Sara", "Selma", "Sofia", "Tarik", "Una", "Vedran", "Zara",
+	"Željko", "Šejla", "Ćamil", "Đorđe", "Lucía", "Mateo", "Zoë", "Jörg",
+}
+
+var lastNames = []string{
+	"Babić", "Begić", "Brown", "Ćosić", "Delić", "Dedić", "Garcia", "Hadžić",
+	"Hodžić", "Horvat", "Ibrahimović", "Jovanović", "Kovačević", "Kovač",
+	"Müller", "Martin", "Mehić", "Nikolić", "Novak", "Omerović", "Petrović",
+	"Popović", "Rossi", "Schmidt", "Smith", "Subašić", "Šarić", "Tahirović",
+	"Taylor", "Vuković", "Williams", "Zukić", "Đurić", "Lindqvist", "Silva",
+}
+
+var domains = []string{"example.com", "example.org", "example.net", "mail.example"}
+
+var departments = []string{"Engineering", "Finance", "Marketing", "Operations", "Sales", "Support"}
+
+var titles = []string{"Analyst", "Engineer", "Manager", "Specialist", "Director", "Intern"}
+
+// ascii spells names the way they usually appear in email addresses.
+var ascii = strings.NewReplacer(
+	"č", "c", "ć", "c", "đ", "dj", "š", "s", "ž", "z",
+	"ä", "a", "ö", "o", "ü", "u", "ë", "e", "í", "i", "é", "e", "á", "a",
+)
+
+// Generate returns n users. The same seed always yields the same users, and
+// emails are unique within the result. The seed is part of every email, as
+// in amina.babic+42@example.com, so runs with different seeds can share a
+// database.
+func Generate(n int, seed uint64) []database.User {
+	r := rand.New(rand.NewPCG(seed, seed))
+	taken := make(map[string]bool, n)
+
+	users := make([]database.User, n)
+	for i := range users {
+		first := firstNames[r.IntN(len(firstNames))]
+		last := lastNames[r.IntN(len(lastNames))]
+
+		local := ascii.Replace(strings.ToLower(first + "." + last))
+		domain := domains[r.IntN(len(domains))]
+		tag := "+" + strconv.FormatUint(seed, 10) + "@" + domain
+		email := local + tag
+		for k := 2; taken[email]; k++ {
+			email = local + strconv.Itoa(k) + tag
+		}
+		taken[email] = true
+
+		users[i] = database.User{
+			Name:       first + " " + last,
+			Email:      email,
+			BirthDate:  birthDate(r),
+			Department: departments[r.IntN(len(departments))],
+			Title:      titles[r.IntN(len(titles))],
+		}
+	}
+	return users
+}
+
+// birthDate gives ages between 18 and 81, most of them around the late
+// thirties. Only the birth year depends on the current date, so a seed
+// yields the same users all year.
+func birthDate(r *rand.Rand) time.Time {
+	year := time.Now().Year() - 19 - (r.IntN(32) + r.IntN(32))
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, r.IntN(365))
+}
+
+// Repository is the part of the user store the seeder writes to.
+type Repository interface {
+	CreateUsers(ctx context.Context, users []database.User) error
+	DeleteAllUsers(ctx context.Context) (int64, map[int64]string, error)
+}
+
+type Options struct {
+	Count int
+	Seed  uint64
+	// BatchSize is how many users are inserted per transaction.
+	BatchSize int
+	// Wipe deletes every existing user first.
+	Wipe bool
+}
+
+// Result reports what Run did.
+type Result struct {
+	Deleted int64
+	// Avatars are the avatar versions of the deleted users that had one, by
+	// user id. Their files are left for the caller to delete.
+	Avatars map[int64]string
+	Created int
+}
+
+// Run generates opts.Count users and inserts them in batches. A failing
+// batch stops the run; batches before it stay committed.
+func Run(ctx context.Context, repo Repository, opts Options) (Result, error) {
+	var res Result
+
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 500
+	}
+
+	if opts.Wipe {
+		n, avatars, err := repo.DeleteAllUsers(ctx)
+		if err != nil {
+			return res, fmt.Errorf("wipe users: %w", err)
+		}
+		res.Deleted, res.Avatars = n, avatars
+	}
+
+	users := Generate(opts.Count, opts.Seed)
+	for start := 0; start < len(users); start += opts.BatchSize {
+		end := min(start+opts.BatchSize, len(users))
+		if err := repo.CreateUsers(ctx, users[start:end]); err != nil {
+			if database.IsDuplicate(err) {
+				return res, fmt.Errorf("%w (users from seed %d already exist; use --wipe or another --seed)", err, opts.Seed)
+			}
+			return res, err
+		}
+		res.Created = end
+	}
+	return res, nil
+}
@@ -0,0 +1,118 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"goapp/internal/pkg/database"
+	"reflect"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type fakeRepo struct {
+	calls   []string
+	batches []int
+	failAt  int
+	// emails are those of the users created so far, which must be unique
+	// as in the users table.
+	emails map[string]bool
+}
+
+func (f *fakeRepo) CreateUsers(ctx context.Context, users []database.User) error {
+	f.calls = append(f.calls, "create")
+	f.batches = append(f.batches, len(users))
+	if len(f.batches) == f.failAt {
+		return errors.New("boom")
+	}
+	if f.emails == nil {
+		f.emails = make(map[string]bool)
+	}
+	for _, u := range users {
+		if f.emails[u.Email] {
+			return &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '" + u.Email + "'"}
+		}
+		f.emails[u.Email] = true
+	}
+	return nil
+}
+
+func (f *fakeRepo) DeleteAllUsers(ctx context.Context) (int64, map[int64]string, error) {
+	f.calls = append(f.calls, "wipe")
+	return 3, map[int64]string{2: "v1"}, nil
+}
+
+func TestGenerate_DeterministicAndValid(t *testing.T) {
+	a := Generate(2000, 42)
+	b := Generate(2000, 42)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("expected the same users for the same seed")
+	}
+	if reflect.DeepEqual(a[:10], Generate(10, 43)) {
+		t.Fatalf("expected different users for another seed")
+	}
+
+	emails := make(map[string]bool)
+	for _, u := range a {
+		if err := u.Validate(); err != nil {
+			t.Fatalf("generated invalid user %+v: %v", u, err)
+		}
+		if age := u.Age(); age < 18 || age > 81 {
+			t.Fatalf("expected age between 18 and 81, got %d", age)
+		}
+		if emails[u.Email] {
+			t.Fatalf("duplicate email %s", u.Email)
+		}
+		emails[u.Email] = true
+	}
+}
+
+func TestRun_WipesThenInsertsInBatches(t *testing.T) {
+	repo := &fakeRepo{}
+
+	res, err := Run(context.Background(), repo, Options{Count: 25, Seed: 1, BatchSize: 10, Wipe: true})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(repo.calls, []string{"wipe", "create", "create", "create"}) {
+		t.Fatalf("unexpected calls %v", repo.calls)
+	}
+	if !reflect.DeepEqual(repo.batches, []int{10, 10, 5}) {
+		t.Fatalf("expected batches of 10, 10 and 5, got %v", repo.batches)
+	}
+	if res.Deleted != 3 || res.Created != 25 || res.Avatars[2] != "v1" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestRun_StopsAtFailingBatch(t *testing.T) {
+	repo := &fakeRepo{failAt: 2}
+
+	res, err := Run(context.Background(), repo, Options{Count: 25, Seed: 1, BatchSize: 10})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if res.Created != 10 || len(repo.batches) != 2 {
+		t.Fatalf("expected one committed batch, got %+v after %v", res, repo.batches)
+	}
+}
+
+func TestRun_AnotherSeedAddsUsers(t *testing.T) {
+	repo := &fakeRepo{}
+
+	if _, err := Run(context.Background(), repo, Options{Count: 500, Seed: 1}); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if _, err := Run(context.Background(), repo, Options{Count: 500, Seed: 2}); err != nil {
+		t.Fatalf("expected another seed to add users, got %v", err)
+	}
+	if len(repo.emails) != 1000 {
+		t.Fatalf("expected 1000 users, got %d", len(repo.emails))
+	}
+
+	_, err := Run(context.Background(), repo, Options{Count: 10, Seed: 1})
+	if !database.IsDuplicate(err) {
+		t.Fatalf("expected the same seed to collide, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocal_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocal(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	testRoundTrip(t, s)
+
+	// Nothing but the object is left behind.
+	entries, err := os.ReadDir(filepath.Join(dir, "blobs", "avatars", "7"))
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty directory, got %v %v", entries, err)
+	}
+}
+
+func TestLocal_InvalidKeys(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	for _, key := range []string{"", ".", "../x", "a/../../x", "/etc/passwd", `a\..\x`, "a//b"} {
+		if err := s.Put(context.Background(), key, strings.NewReader("x"), 1, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("%q: expected ErrInvalidKey, got %v", key, err)
+		}
+		if _, err := s.Get(context.Background(), key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("%q: expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
+
+func TestLocal_ShortWriteKeepsOldObject(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	ctx := context.Background()
+	if err := s.Put(ctx, "a.txt", strings.NewReader("old"), 3, "text/plain"); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if err := s.Put(ctx, "a.txt", strings.NewReader("new"), 10, "text/plain"); err == nil {
+		t.Fatalf("expected an error for a short body")
+	}
+
+	obj, err := s.Get(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	defer obj.Close()
+	if b, _ := io.ReadAll(obj); string(b) != "old" {
+		t.Fatalf("expected the old object, got %q", b)
+	}
+}
+
+// testRoundTrip stores, reads, replaces and deletes an object in s.
+func testRoundTrip(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	key := "avatars/7/3f9a.jpg"
+
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for _, body := range []string{"first", "second version"} {
+		if err := s.Put(ctx, key, strings.NewReader(body), int64(len(body)), "image/jpeg"); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		obj, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		b, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil || string(b) != body || obj.ContentType != "image/jpeg" || obj.Size != int64(len(body)) {
+			t.Fatalf("unexpected object %q %q %d %v", b, obj.ContentType, obj.Size, err)
+		}
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("expected deleting a missing object to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Package storage keeps uploaded files such as avatars in a local
+// directory or an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/config"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Store holds objects under slash-separated keys such as
+// "avatars/7/3f9a.jpg".
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any object
+	// already there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key; the caller closes it. It
+	// returns ErrNotFound when there is none.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object stored under key. Deleting a missing
+	// object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is an open stored object.
+type Object struct {
+	io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// New returns the store cfg selects.
+func New(cfg config.StorageConfig) (Store, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "local":
+		return NewLocal(cfg.Path)
+	case "s3":
+		return NewS3(cfg.S3, http.DefaultClient)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// checkKey rejects keys that could escape the store's root, such as
+// "../x" or "/etc/passwd".
+func checkKey(key string) error {
+	if key == "." || !fs.ValidPath(key) || strings.Contains(key, `\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"goapp/internal/pkg/config"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup_StdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{
+		Exporter:    "stdout",
+		ServiceName: "goapp-test",
+		SampleRatio: 1,
+	}, &buf)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "test-span") {
+		t.Fatalf("expected exported span, got %q", buf.String())
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), config.TracingConfig{Exporter: "zipkin"}, &bytes.Buffer{}); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
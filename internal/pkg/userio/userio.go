@@ -0,0 +1,226 @@
+// Package userio reads and writes users as CSV or JSON for import and
+// export.
+package userio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapp/internal/pkg/attributes"
+	"goapp/internal/pkg/database"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var csvHeader = []string{"id", "name", "email", "birth_date", "birth_date_approximate", "created_at", "updated_at", "phone", "department", "title", "locale", "tags"}
+
+// attrPrefix starts the CSV column of a custom attribute, as in
+// "attr.cost_center".
+const attrPrefix = "attr."
+
+// FormatFromPath guesses the format from a file extension, falling back to
+// CSV.
+func FormatFromPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return FormatJSON
+	}
+	return FormatCSV
+}
+
+// Write writes users in format. defs are the custom attributes, which get
+// a column each in CSV.
+func Write(w io.Writer, format string, users []database.User, defs attributes.Defs) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, users, defs)
+	case FormatJSON:
+		return WriteJSON(w, users)
+	}
+	return fmt.Errorf("unknown format %q (expected csv or json)", format)
+}
+
+// Read reads users in format, checking their attributes against defs.
+func Read(r io.Reader, format string, defs attributes.Defs) ([]database.User, error) {
+	switch format {
+	case FormatCSV:
+		return ReadCSV(r, defs)
+	case FormatJSON:
+		return ReadJSON(r, defs)
+	}
+	return nil, fmt.Errorf("unknown format %q (expected csv or json)", format)
+}
+
+func WriteCSV(w io.Writer, users []database.User, defs attributes.Defs) error {
+	cw := csv.NewWriter(w)
+	header := append([]string(nil), csvHeader...)
+	for _, d := range defs {
+		header = append(header, attrPrefix+d.Name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, u := range users {
+		record := []string{
+			strconv.FormatInt(u.ID, 10),
+			u.Name,
+			u.Email,
+			u.BirthDate.Format(time.DateOnly),
+			strconv.FormatBool(u.BirthDateApproximate),
+			formatTime(u.CreatedAt),
+			formatTime(u.UpdatedAt),
+			u.Phone,
+			u.Department,
+			u.Title,
+			u.Locale,
+			database.FormatTags(u.Tags),
+		}
+		for _, d := range defs {
+			record = append(record, attributes.Format(u.Attributes[d.Name]))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func WriteJSON(w io.Writer, users []database.User) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(users)
+}
+
+// ReadCSV reads users from CSV with a header row. Columns are matched by
+// name and may come in any order; the id and timestamp columns are ignored
+// so an export can be imported into another database. Only name, email
+// and birth_date are required; files with an age column instead, as
+// exported before birth dates were stored, get approximate birth dates.
+// The tags column is a comma separated list.
+func ReadCSV(r io.Reader, defs attributes.Defs) ([]database.User, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty input")
+		}
+		return nil, err
+	}
+
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"name", "email"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("missing %q column", name)
+		}
+	}
+	_, hasBirthDate := cols["birth_date"]
+	if _, hasAge := cols["age"]; !hasBirthDate && !hasAge {
+		return nil, errors.New(`missing "birth_date" column`)
+	}
+
+	users := make([]database.User, 0)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		get := func(name string) string {
+			if i, ok := cols[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		u := database.User{
+			Name:       get("name"),
+			Email:      get("email"),
+			Phone:      get("phone"),
+			Department: get("department"),
+			Title:      get("title"),
+			Locale:     get("locale"),
+		}
+		if hasBirthDate {
+			if s := get("birth_date"); s != "" {
+				if u.BirthDate, err = database.ParseDate(s); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+			u.BirthDateApproximate, _ = strconv.ParseBool(get("birth_date_approximate"))
+		} else {
+			age, err := strconv.Atoi(get("age"))
+			if err != nil || age <= 0 {
+				return nil, fmt.Errorf("line %d: age must be a number greater than 0", line)
+			}
+			u.BirthDate, u.BirthDateApproximate = database.ApproximateBirthDate(age), true
+		}
+		if u.Tags, err = database.ParseTags(get("tags")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		attrs := make(map[string]any)
+		for name, i := range cols {
+			if attr, ok := strings.CutPrefix(name, attrPrefix); ok {
+				attrs[attr] = record[i]
+			}
+		}
+		if u.Attributes, err = defs.Normalize(attrs); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := u.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// ReadJSON reads a JSON array of users as written by WriteJSON. IDs and
+// timestamps are ignored like in ReadCSV, and so are managers, whose IDs
+// don't carry over to the imported users, and avatars, whose files don't.
+func ReadJSON(r io.Reader, defs attributes.Defs) ([]database.User, error) {
+	var users []database.User
+	if err := json.NewDecoder(r).Decode(&users); err != nil {
+		return nil, err
+	}
+
+	for i := range users {
+		users[i].ID, users[i].ManagerID, users[i].Avatar = 0, nil, ""
+		users[i].CreatedAt, users[i].UpdatedAt = time.Time{}, time.Time{}
+		attrs, err := defs.Normalize(users[i].Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("user %d: %w", i+1, err)
+		}
+		users[i].Attributes = attrs
+		if users[i].Tags, err = database.NormalizeTags(users[i].Tags); err != nil {
+			return nil, fmt.Errorf("user %d: %w", i+1, err)
+		}
+		if err := users[i].Validate(); err != nil {
+			return nil, fmt.Errorf("user %d: %w", i+1, err)
+		}
+	}
+	return users, nil
+}
@@ -0,0 +1,142 @@
+package userio
+
+import (
+	"bytes"
+	"errors"
+	"goapp/internal/pkg/attributes"
+	"goapp/internal/pkg/database"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSV_RoundTrip(t *testing.T) {
+	users := []database.User{
+		{ID: 1, Name: "Ana, Jr.", Email: "ana@test.com", BirthDate: time.Date(1994, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, Name: "Bo", Email: "bo@test.com", BirthDate: time.Date(1983, 7, 9, 0, 0, 0, 0, time.UTC), BirthDateApproximate: true},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, users, nil); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	got, err := ReadCSV(&buf, nil)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(got))
+	}
+	if got[0].Name != "Ana, Jr." || got[0].ID != 0 || !got[1].BirthDate.Equal(users[1].BirthDate) || !got[1].BirthDateApproximate {
+		t.Fatalf("unexpected users %+v", got)
+	}
+}
+
+func TestWriteCSV_Timestamps(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, []database.User{{ID: 1, Name: "Ana", Email: "ana@test.com", BirthDate: time.Date(1994, 3, 1, 0, 0, 0, 0, time.UTC), CreatedAt: at, UpdatedAt: at}}, nil); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	want := "id,name,email,birth_date,birth_date_approximate,created_at,updated_at,phone,department,title,locale,tags\n1,Ana,ana@test.com,1994-03-01,false,2024-05-01T10:00:00Z,2024-05-01T10:00:00Z,,,,,\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestReadCSV_ColumnsInAnyOrder(t *testing.T) {
+	got, err := ReadCSV(strings.NewReader("Email,Age,Name\nana@test.com,30,Ana\n"), nil)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if got[0].Name != "Ana" || got[0].Email != "ana@test.com" || got[0].Age() != 30 || !got[0].BirthDateApproximate {
+		t.Fatalf("unexpected user %+v", got[0])
+	}
+}
+
+func TestReadCSV_ReportsLine(t *testing.T) {
+	_, err := ReadCSV(strings.NewReader("name,email,age\nAna,ana@test.com,30\nBo,not-an-email,20\n"), nil)
+	if !errors.Is(err, database.ErrInvalidEmail) || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected invalid email on line 3, got %v", err)
+	}
+
+	if _, err := ReadCSV(strings.NewReader("name,email\nAna,ana@test.com\n"), nil); err == nil {
+		t.Fatalf("expected error for missing birth_date column")
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	got, err := ReadJSON(strings.NewReader(`[{"id":7,"name":"Ana","email":"ana@test.com","age":30,"manager_id":3}]`), nil)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 0 || got[0].Name != "Ana" || got[0].ManagerID != nil {
+		t.Fatalf("unexpected users %+v", got)
+	}
+
+	if _, err := ReadJSON(strings.NewReader(`[{"name":"Ana","email":"ana@test.com","age":0}]`), nil); !errors.Is(err, database.ErrBirthDateRequired) {
+		t.Fatalf("expected ErrBirthDateRequired, got %v", err)
+	}
+}
+
+func TestCSV_ProfileAndAttributes(t *testing.T) {
+	defs := attributes.Defs{
+		{Name: "cost_center", Type: attributes.String},
+		{Name: "remote", Type: attributes.Bool},
+	}
+	users := []database.User{{
+		ID: 1, Name: "Ana", Email: "ana@test.com", BirthDate: time.Date(1994, 3, 1, 0, 0, 0, 0, time.UTC),
+		Phone: "+387 61 123 456", Department: "IT", Title: "Engineer", Locale: "bs",
+		Attributes: map[string]any{"remote": true},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, users, defs); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if header, _, _ := strings.Cut(buf.String(), "\n"); !strings.HasSuffix(header, ",locale,tags,attr.cost_center,attr.remote") {
+		t.Fatalf("expected attribute columns, got %q", header)
+	}
+
+	got, err := ReadCSV(&buf, defs)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	u := got[0]
+	if u.Phone != "+387 61 123 456" || u.Title != "Engineer" || u.Locale != "bs" || !reflect.DeepEqual(u.Attributes, map[string]any{"remote": true}) {
+		t.Fatalf("unexpected user %+v", u)
+	}
+
+	_, err = ReadCSV(strings.NewReader("name,email,age,attr.shoe_size\nAna,ana@test.com,30,42\n"), defs)
+	if !errors.Is(err, attributes.ErrUnknown) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected unknown attribute on line 2, got %v", err)
+	}
+}
+
+func TestCSV_Tags(t *testing.T) {
+	users := []database.User{{Name: "Ana", Email: "ana@test.com", BirthDate: time.Date(1994, 3, 1, 0, 0, 0, 0, time.UTC), Tags: []string{"contractor", "on-call"}}}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, users, nil); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), `,"contractor, on-call"`) {
+		t.Fatalf("expected quoted tags, got %q", buf.String())
+	}
+
+	got, err := ReadCSV(&buf, nil)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !reflect.DeepEqual(got[0].Tags, users[0].Tags) {
+		t.Fatalf("expected tags %v, got %v", users[0].Tags, got[0].Tags)
+	}
+
+	_, err = ReadCSV(strings.NewReader("name,email,age,tags\nAna,ana@test.com,30,\"ok, not ok\"\n"), nil)
+	if !errors.Is(err, database.ErrInvalidTag) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected invalid tag on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+// Package templates holds the HTML templates and static assets, embedded
+// into the binary so it runs from any working directory.
+package templates
+
+import "embed"
+
+// FS contains the pages and layout.html at its root, shared partials under
+// partials/ and the static assets under static/.
+//
+//go:embed *.html partials static
+var FS embed.FS
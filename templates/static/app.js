@@ -0,0 +1,166 @@
+// Partial page updates for the users page.
+//
+// Links and forms marked with data-partial are fetched with an HX-Request
+// header, and the server answers with just the fragment to swap in for the
+// element named by data-target:
+//
+//   region  replace the target with the response (create form)
+//   page    like region, and record the URL in history (pagination)
+//   search  like region, and as the user types if the form has data-live
+//           (search form)
+//   row     replace a table row (inline edit, save and cancel)
+//   remove  remove the target once the request succeeds (delete)
+//
+// A link's data-href, when set, is fetched instead of its href. Without
+// JavaScript, or when a request fails, links and forms work as usual.
+(function () {
+  "use strict";
+
+  var searchDelay = 250;
+
+  function fetchFragment(method, url, body, signal) {
+    return fetch(url, {
+      method: method,
+      body: body,
+      headers: { "HX-Request": "true" },
+      credentials: "same-origin",
+      signal: signal,
+    }).then(function (res) {
+      // 400 responses carry the fragment with the validation error.
+      if (!res.ok && res.status !== 400) {
+        throw new Error(res.status + " " + res.statusText);
+      }
+      return res.text();
+    });
+  }
+
+  function swap(target, mode, html) {
+    if (mode === "remove") {
+      target.remove();
+      return;
+    }
+    var tpl = document.createElement("template");
+    tpl.innerHTML = html.trim();
+    target.replaceWith(tpl.content);
+  }
+
+  // load fetches url for el and swaps the result in, calling fallback when
+  // that isn't possible. An aborted request does neither.
+  function load(el, method, url, body, fallback, signal) {
+    var mode = el.dataset.partial;
+    var target = document.getElementById(el.dataset.target);
+    if (!target) {
+      fallback();
+      return Promise.resolve(false);
+    }
+    return fetchFragment(method, url, body, signal).then(
+      function (html) {
+        swap(target, mode, html);
+        return true;
+      },
+      function (err) {
+        if (err.name !== "AbortError") {
+          fallback();
+        }
+        return false;
+      }
+    );
+  }
+
+  function formURL(form) {
+    var url = new URL(form.action, location.href);
+    url.search = new URLSearchParams(new FormData(form)).toString();
+    return url;
+  }
+
+  // searchController aborts the search in flight when the next one starts,
+  // so a slow response can't replace the results of a later search.
+  var searchController;
+
+  function submitForm(form) {
+    var method = (form.getAttribute("method") || "GET").toUpperCase();
+    var fallback = function () { form.submit(); };
+    if (method === "GET") {
+      var url = formURL(form);
+      var signal;
+      if (form.dataset.partial === "search") {
+        if (searchController) {
+          searchController.abort();
+        }
+        searchController = new AbortController();
+        signal = searchController.signal;
+      }
+      return load(form, "GET", url, null, fallback, signal).then(function (ok) {
+        if (ok) history.replaceState(null, "", url);
+      });
+    }
+    return load(form, method, form.action, new URLSearchParams(new FormData(form)), fallback);
+  }
+
+  // syncSearch copies the list parameters of the current URL into the
+  // search form, which sits outside the region that page links replace.
+  function syncSearch() {
+    var form = document.querySelector("form[data-partial=search]");
+    if (!form) {
+      return;
+    }
+    var params = new URL(location.href).searchParams;
+    Array.prototype.forEach.call(form.elements, function (el) {
+      if (!el.name || el.name === "limit") {
+        return;
+      }
+      if (el.type === "checkbox") {
+        el.checked = params.getAll(el.name).indexOf(el.value) !== -1;
+      } else {
+        el.value = params.get(el.name) || "";
+      }
+    });
+  }
+
+  document.addEventListener("click", function (e) {
+    var a = e.target.closest("a[data-partial]");
+    if (!a || e.defaultPrevented || e.button !== 0 || e.metaKey || e.ctrlKey || e.shiftKey || e.altKey) {
+      return;
+    }
+    e.preventDefault();
+    var url = a.dataset.href || a.href;
+    load(a, "GET", url, null, function () { location.href = a.href; }).then(function (ok) {
+      if (ok && a.dataset.partial === "page") {
+        history.pushState(null, "", a.href);
+        syncSearch();
+      }
+    });
+  });
+
+  document.addEventListener("submit", function (e) {
+    var form = e.target;
+    if (e.defaultPrevented || !form.matches("form[data-partial]")) {
+      return;
+    }
+    e.preventDefault();
+    submitForm(form);
+  });
+
+  var searchTimer;
+  document.addEventListener("input", function (e) {
+    var form = e.target.form;
+    if (!form || form.dataset.partial !== "search" || !("live" in form.dataset)) {
+      return;
+    }
+    clearTimeout(searchTimer);
+    searchTimer = setTimeout(function () { submitForm(form); }, searchDelay);
+  });
+
+  // Going back or forward between pages reloads the table.
+  window.addEventListener("popstate", function () {
+    var target = document.getElementById("users-content");
+    if (!target) {
+      return;
+    }
+    syncSearch();
+    fetchFragment("GET", location.href).then(
+      function (html) { swap(target, "region", html); },
+      function () { location.reload(); }
+    );
+  });
+})();